@@ -81,14 +81,22 @@ func CreateClient(ctx context.Context, name, key string, opts ...Option) (res *C
 	return client
 }
 
+// NewClient 使用已创建的 *gorm.DB 返回数据库客户端，不读取统一配置，不注册读写分离插件
+// @key string database config key
+// @db *gorm.DB 已创建的数据库实例
+func NewClient(key string, db *gorm.DB) *Client {
+	return &Client{key: key, config: &Config{}, db: db}
+}
+
 // Key return the database config key
 func (c *Client) Key() string {
 	return c.key
 }
 
 // Master database master, for write connection
+// 通过其开启的事务支持 AfterCommit 注册提交后回调
 func (c *Client) Master() *gorm.DB {
-	return c.db.Clauses(dbresolver.Write)
+	return hookable(c.db.Clauses(dbresolver.Write))
 }
 
 // Slave database slave, for read connection
@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// txBeginner 包装数据库连接池，开启的事务使用 hookTx 包装，支持注册提交后回调
+	txBeginner struct {
+		gorm.ConnPool
+	}
+
+	// hookTx 包装事务连接，提交成功后执行通过 AfterCommit 注册的回调，回滚时丢弃回调。
+	// 同一事务派生的 *gorm.DB（链式调用、Session、嵌套事务）共享同一个 hookTx
+	hookTx struct {
+		gorm.ConnPool
+		committer gorm.TxCommitter
+		mu        sync.Mutex
+		hooks     []txHookFunc
+	}
+
+	txHookFunc struct {
+		ctx context.Context
+		fn  func(ctx context.Context)
+	}
+)
+
+// hookable 返回开启事务时支持 AfterCommit 的数据库实例
+func hookable(db *gorm.DB) *gorm.DB {
+	if _, ok := db.Statement.ConnPool.(*txBeginner); !ok {
+		db.Statement.ConnPool = &txBeginner{ConnPool: db.Statement.ConnPool}
+	}
+	return db
+}
+
+// BeginTx 开启事务并使用 hookTx 包装
+func (b *txBeginner) BeginTx(ctx context.Context, opts *sql.TxOptions) (pool gorm.ConnPool, err error) {
+	switch beginner := b.ConnPool.(type) {
+	case gorm.TxBeginner:
+		pool, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		pool, err = beginner.BeginTx(ctx, opts)
+	default:
+		err = gorm.ErrInvalidTransaction
+	}
+
+	if err != nil {
+		return
+	}
+
+	return newHookTx(pool)
+}
+
+// newHookTx 使用 hookTx 包装事务连接
+func newHookTx(pool gorm.ConnPool) (*hookTx, error) {
+	committer, ok := pool.(gorm.TxCommitter)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	return &hookTx{ConnPool: pool, committer: committer}, nil
+}
+
+// Commit 提交事务，成功后按注册顺序执行回调
+func (t *hookTx) Commit() error {
+	if err := t.committer.Commit(); err != nil {
+		t.takeHooks()
+		return err
+	}
+
+	for _, hook := range t.takeHooks() {
+		hook.fn(hook.ctx)
+	}
+
+	return nil
+}
+
+// Rollback 回滚事务并丢弃回调
+func (t *hookTx) Rollback() error {
+	t.takeHooks()
+	return t.committer.Rollback()
+}
+
+// takeHooks 取出并清空已注册的回调
+func (t *hookTx) takeHooks() []txHookFunc {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hooks := t.hooks
+	t.hooks = nil
+
+	return hooks
+}
+
+// AfterCommit 注册事务提交成功后执行的回调，回调按注册顺序执行，回滚或提交失败时丢弃。
+// tx为nil或不是事务时立即执行。
+// 通过 Client.Begin 或 Client.Master().Transaction 开启的事务，使用 tx.Commit()、Transaction 提交
+// 以及在派生的 *gorm.DB、嵌套事务中注册均可生效；嵌套事务回滚到保存点时，其中注册的回调仍在外层事务提交后执行。
+// 其他方式开启的事务需通过传入的 tx 提交
+func AfterCommit(ctx context.Context, tx *gorm.DB, fn func(ctx context.Context)) {
+	if fn == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if tx == nil {
+		fn(ctx)
+		return
+	}
+
+	t, ok := tx.Statement.ConnPool.(*hookTx)
+	if !ok {
+		var err error
+		if t, err = newHookTx(tx.Statement.ConnPool); err != nil {
+			fn(ctx)
+			return
+		}
+		tx.Statement.ConnPool = t
+	}
+
+	t.mu.Lock()
+	t.hooks = append(t.hooks, txHookFunc{ctx: ctx, fn: fn})
+	t.mu.Unlock()
+}
@@ -0,0 +1,88 @@
+package resty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	resty2 "github.com/go-resty/resty/v2"
+	"github.com/mel0dys0ng/song/pkg/breaker"
+)
+
+type (
+	// breakerDoneKey 请求ctx中熔断器结果回调的键
+	breakerDoneKey struct{}
+
+	// breakerTransport 记录每次HTTP请求（包括重试）的结果到熔断器
+	breakerTransport struct {
+		next http.RoundTripper
+	}
+)
+
+// useBreaker 按请求的host使用熔断器，熔断器名称为 resty:配置键:host。
+// 熔断器打开时请求返回 breaker.ErrCircuitOpen，除非自定义重试条件要求重试，否则不重试；网络错误和5xx响应计为失败。
+// 请求未发出（后续请求中间件失败或panic）时以该错误记录结果，避免半开状态的探测名额泄漏
+func (c *Client) useBreaker() {
+	c.OnBeforeRequest(func(client *resty2.Client, r *resty2.Request) error {
+		done, err := breaker.Get(c.breakerName(client, r), c.config.breakerOptions...).Allow()
+		if err != nil {
+			return err
+		}
+
+		r.SetContext(context.WithValue(r.Context(), breakerDoneKey{}, done))
+		return nil
+	})
+
+	// done只生效一次，已在RoundTrip中记录结果时不会重复记录
+	release := func(r *resty2.Request, err error) {
+		if done, ok := r.Context().Value(breakerDoneKey{}).(func(err error)); ok {
+			done(err)
+		}
+	}
+	c.OnError(release)
+	c.OnPanic(release)
+
+	next := c.GetClient().Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.SetTransport(&breakerTransport{next: next})
+}
+
+// breakerName 返回请求host对应的熔断器名称，请求URL为相对路径时使用BaseURL的host
+func (c *Client) breakerName(client *resty2.Client, r *resty2.Request) string {
+	host := ""
+	if u, err := url.Parse(r.URL); err == nil {
+		host = u.Host
+	}
+
+	if len(host) == 0 {
+		if u, err := url.Parse(client.BaseURL); err == nil {
+			host = u.Host
+		}
+	}
+
+	return fmt.Sprintf("resty:%s:%s", c.key, host)
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	resp, err = t.next.RoundTrip(req)
+
+	done, ok := req.Context().Value(breakerDoneKey{}).(func(err error))
+	if !ok {
+		return
+	}
+
+	switch {
+	case err != nil:
+		done(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		done(fmt.Errorf("http status %d", resp.StatusCode))
+	default:
+		done(nil)
+	}
+
+	return
+}
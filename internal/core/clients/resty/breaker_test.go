@@ -0,0 +1,125 @@
+package resty
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	resty2 "github.com/go-resty/resty/v2"
+	"github.com/mel0dys0ng/song/pkg/breaker"
+	"github.com/mel0dys0ng/song/pkg/metas"
+)
+
+const testFailHeader = "X-Test-Fail"
+
+// newBreakerClient 返回启用熔断器的客户端，请求头带有 testFailHeader 时请求中间件失败
+func newBreakerClient(t *testing.T, retryCount int, opts ...breaker.Option) *Client {
+	t.Helper()
+
+	// 熔断器状态变化时通过erlogs记录日志，需要先初始化元数据
+	metas.Initialize(&metas.Options{App: "test", Kind: metas.KindTool, Mode: metas.ModeLocal, Config: t.TempDir()})
+
+	c := &Client{
+		key:    t.Name(),
+		config: &Config{breaker: true, breakerOptions: opts},
+		Client: resty2.New(),
+	}
+	c.SetRetryCount(retryCount)
+	c.SetRetryWaitTime(time.Millisecond)
+	c.SetRetryMaxWaitTime(time.Millisecond)
+	c.useBreaker()
+	c.OnBeforeRequest(func(_ *resty2.Client, r *resty2.Request) error {
+		if r.Header.Get(testFailHeader) != "" {
+			return errors.New("before request failed")
+		}
+		return nil
+	})
+
+	return c
+}
+
+// newStatusServer 返回以status响应的服务，calls记录收到的请求数量
+func newStatusServer(t *testing.T, status *atomic.Int32) (srv *httptest.Server, calls *atomic.Int32) {
+	t.Helper()
+
+	calls = &atomic.Int32{}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(srv.Close)
+
+	return
+}
+
+func TestBreaker(t *testing.T) {
+	status := &atomic.Int32{}
+	status.Store(http.StatusInternalServerError)
+	failing, failingCalls := newStatusServer(t, status)
+
+	healthy := &atomic.Int32{}
+	healthy.Store(http.StatusOK)
+	other, otherCalls := newStatusServer(t, healthy)
+
+	c := newBreakerClient(t, 3, breaker.ConsecutiveFailures(2), breaker.OpenTimeout(time.Minute))
+	retries := 0
+	c.AddRetryHook(func(*resty2.Response, error) { retries++ })
+
+	// 5xx响应计为失败，连续失败2次后熔断器打开
+	for range 2 {
+		if rsp, err := c.Client.R().Get(failing.URL); err != nil || rsp.StatusCode() != http.StatusInternalServerError {
+			t.Fatalf("rsp = %v, err = %v", rsp, err)
+		}
+	}
+	if state := breaker.Get(c.breakerName(c.Client, &resty2.Request{URL: failing.URL})).State(); state != breaker.StateOpen {
+		t.Fatalf("state = %v, want %v", state, breaker.StateOpen)
+	}
+
+	// 熔断器打开时请求不发出也不重试
+	if _, err := c.Client.R().Get(failing.URL); !errors.Is(err, breaker.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want %v", err, breaker.ErrCircuitOpen)
+	}
+	if failingCalls.Load() != 2 || retries != 0 {
+		t.Fatalf("calls = %d, retries = %d", failingCalls.Load(), retries)
+	}
+
+	// 不同host使用各自的熔断器
+	rsp, err := c.Client.R().Get(other.URL)
+	if err != nil || rsp.StatusCode() != http.StatusOK || otherCalls.Load() != 1 {
+		t.Fatalf("other host: rsp = %v, err = %v, calls = %d", rsp, err, otherCalls.Load())
+	}
+}
+
+func TestBreakerBeforeRequestError(t *testing.T) {
+	status := &atomic.Int32{}
+	status.Store(http.StatusInternalServerError)
+	srv, calls := newStatusServer(t, status)
+
+	openTimeout := 20 * time.Millisecond
+	c := newBreakerClient(t, 0,
+		breaker.ConsecutiveFailures(1), breaker.OpenTimeout(openTimeout), breaker.HalfOpenMaxCalls(1),
+	)
+
+	if _, err := c.Client.R().Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// 半开状态的探测请求在发出前失败时记录结果并释放探测名额，熔断器重新打开
+	time.Sleep(2 * openTimeout)
+	if _, err := c.Client.R().SetHeader(testFailHeader, "1").Get(srv.URL); err == nil || errors.Is(err, breaker.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want before request error", err)
+	}
+	if state := breaker.Get(c.breakerName(c.Client, &resty2.Request{URL: srv.URL})).State(); state != breaker.StateOpen {
+		t.Fatalf("state = %v, want %v", state, breaker.StateOpen)
+	}
+
+	status.Store(http.StatusOK)
+	time.Sleep(2 * openTimeout)
+	rsp, err := c.Client.R().Get(srv.URL)
+	if err != nil || rsp.StatusCode() != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("rsp = %v, err = %v, calls = %d", rsp, err, calls.Load())
+	}
+}
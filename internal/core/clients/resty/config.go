@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/mel0dys0ng/song/pkg/breaker"
 	"github.com/mel0dys0ng/song/pkg/erlogs"
 	"github.com/mel0dys0ng/song/pkg/vipers"
 )
@@ -45,6 +46,12 @@ type Config struct {
 
 	// SignConfig 签名配置
 	SignConfig *SignConfig `json:"signConfig" yaml:"signConfig" mapstructure:"signConfig"`
+
+	// breaker 是否按host启用熔断器，仅支持通过 Breaker 选项配置
+	breaker bool
+
+	// breakerOptions 熔断器配置
+	breakerOptions []breaker.Option
 }
 
 // SignConfig 签名配置结构体
@@ -2,6 +2,8 @@ package resty
 
 import (
 	"time"
+
+	"github.com/mel0dys0ng/song/pkg/breaker"
 )
 
 const (
@@ -97,3 +99,11 @@ func SignTTL(t int) Option {
 		c.SignTTL = t
 	}
 }
+
+// Breaker 按请求的host启用熔断器，同一配置键下相同host的请求共享熔断器
+func Breaker(opts ...breaker.Option) Option {
+	return func(c *Config) {
+		c.breaker = true
+		c.breakerOptions = opts
+	}
+}
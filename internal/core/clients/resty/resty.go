@@ -52,6 +52,10 @@ func CreateClient(ctx context.Context, name, key string, opts ...Option) *Client
 	client.SetRetryWaitTime(config.RetryWaitTime)
 	client.SetRetryMaxWaitTime(config.RetryWaitMaxTime)
 
+	if config.breaker {
+		client.useBreaker()
+	}
+
 	// 存储到缓存中
 	clients.Store(mk, client)
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/ratelimit"
 	"github.com/mel0dys0ng/song/pkg/sys"
 	"github.com/mel0dys0ng/song/pkg/vipers"
 )
@@ -48,6 +49,7 @@ type (
 		Cors              *Cors          `json:"cors" yaml:"cors" mapstructure:"cors"`
 		Csrf              *CSRF          `json:"csrf" yaml:"csrf" mapstructure:"csrf"`
 		Sign              *Sign          `json:"sign" yaml:"sign" mapstructure:"sign"`
+		RateLimit         *RateLimit     `json:"rateLimit" yaml:"rateLimit" mapstructure:"rateLimit"`
 	}
 
 	Cors struct {
@@ -98,6 +100,9 @@ type (
 		Defers      []Defer
 		Routes      []Route
 		Middlewares []Middleware
+
+		RateLimiter       ratelimit.Limiter           // 自定义限流器，默认根据 RateLimit.Redis 创建
+		RateLimitKeyFuncs map[string]RateLimitKeyFunc // 自定义限流维度的提取函数，key为限流规则的Key
 	}
 
 	Init                 func() error
@@ -0,0 +1,233 @@
+package https
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mel0dys0ng/song/pkg/aob"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/ratelimit"
+	"github.com/mel0dys0ng/song/pkg/redis"
+	"github.com/mel0dys0ng/song/pkg/sys"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitKeyRoute  = "route"  // 按路由限流，所有客户端共享配额
+	RateLimitKeyIP     = "ip"     // 按客户端IP（gin.Context.ClientIP）限流，需通过 gin.Engine.SetTrustedProxies 配置可信代理，gin默认信任所有代理
+	RateLimitKeyDevice = "device" // 按设备ID（客户端上报，不可信）限流，无设备ID时按客户端IP限流
+
+	RateLimitDefaultPeriod = time.Second
+
+	HeaderKeyRetryAfter         = "Retry-After"
+	HeaderKeyRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderKeyRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderKeyRateLimitReset     = "X-RateLimit-Reset"
+)
+
+type (
+	RateLimit struct {
+		Enable bool            `json:"enable" yaml:"enable" mapstructure:"enable"`
+		Redis  string          `json:"redis" yaml:"redis" mapstructure:"redis"` // redis配置key，为空时使用进程内限流
+		Rules  []RateLimitRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+	}
+
+	RateLimitRule struct {
+		Name    string   `json:"name" yaml:"name" mapstructure:"name"`          // 规则名称，用于区分限流key，默认为规则序号
+		Routes  []string `json:"routes" yaml:"routes" mapstructure:"routes"`    // 路由（gin注册的完整路径），以*结尾时按前缀匹配，为空时匹配所有路由
+		Methods []string `json:"methods" yaml:"methods" mapstructure:"methods"` // 请求方法，为空时匹配所有方法
+		Key     string   `json:"key" yaml:"key" mapstructure:"key"`             // 限流维度：route、ip、device或自定义提取函数名称，默认route
+		Rate    int64    `json:"rate" yaml:"rate" mapstructure:"rate"`          // 每个周期放行的请求数
+		Period  string   `json:"period" yaml:"period" mapstructure:"period"`    // 周期，默认1s
+		Burst   int64    `json:"burst" yaml:"burst" mapstructure:"burst"`       // 突发请求数，默认等于rate
+	}
+
+	// RateLimitKeyFunc 自定义限流维度的提取函数，返回空字符串时该规则不生效
+	RateLimitKeyFunc func(ctx *gin.Context) string
+
+	// rateLimitRule 解析后的限流规则
+	rateLimitRule struct {
+		RateLimitRule
+		limit   ratelimit.Limit
+		keyFunc RateLimitKeyFunc
+	}
+)
+
+// setupRateLimitMiddleware 设置限流中间件
+func (s *Server) setupRateLimitMiddleware() gin.HandlerFunc {
+	if s.RateLimit == nil || !s.RateLimit.Enable || len(s.RateLimit.Rules) == 0 {
+		// 如果没有启用限流，直接返回一个不执行任何操作的中间件
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := s.RateLimiter
+	if limiter == nil {
+		if s.RateLimit.Redis != "" {
+			limiter = ratelimit.NewRedis(redis.NewUniversalClient(context.Background(), s.RateLimit.Redis))
+		} else {
+			limiter = ratelimit.NewLocal()
+		}
+	}
+
+	rules := make([]rateLimitRule, 0, len(s.RateLimit.Rules))
+	for i, v := range s.RateLimit.Rules {
+		rules = append(rules, s.parseRateLimitRule(i, v))
+	}
+
+	// 使用限流中间件
+	return newRateLimitMiddleware(limiter, rules)
+}
+
+// parseRateLimitRule 解析限流规则，配置错误时panic
+func (s *Server) parseRateLimitRule(index int, rule RateLimitRule) rateLimitRule {
+	if rule.Name == "" {
+		rule.Name = strconv.Itoa(index)
+	}
+
+	if rule.Key == "" {
+		rule.Key = RateLimitKeyRoute
+	}
+
+	period := RateLimitDefaultPeriod
+	if rule.Period != "" {
+		d, err := time.ParseDuration(rule.Period)
+		if err != nil {
+			sys.Panicf("invalid rate limit rule %s: %s", rule.Name, err.Error())
+		}
+		period = d
+	}
+
+	res := rateLimitRule{
+		RateLimitRule: rule,
+		limit:         ratelimit.Limit{Rate: rule.Rate, Period: period, Burst: rule.Burst},
+	}
+
+	if res.limit.Rate <= 0 || res.limit.Period <= 0 {
+		sys.Panicf("invalid rate limit rule %s: rate and period must be positive", rule.Name)
+	}
+
+	switch rule.Key {
+	case RateLimitKeyRoute:
+		res.keyFunc = func(ctx *gin.Context) string {
+			return ctx.Request.Method + ":" + routeOf(ctx)
+		}
+	case RateLimitKeyIP:
+		// 不使用 ClientInfo.IP：其取自客户端可伪造的 X-Forwarded-For 首个IP，随机伪造即可绕过限流
+		res.keyFunc = func(ctx *gin.Context) string {
+			return ctx.ClientIP()
+		}
+	case RateLimitKeyDevice:
+		res.keyFunc = func(ctx *gin.Context) string {
+			if id := GetClientInfo(ctx).GetDeviceID(); id != "" {
+				return id
+			}
+			return ctx.ClientIP()
+		}
+	default:
+		res.keyFunc = s.RateLimitKeyFuncs[rule.Key]
+		if res.keyFunc == nil {
+			sys.Panicf("invalid rate limit rule %s: key func %s not found", rule.Name, rule.Key)
+		}
+	}
+
+	return res
+}
+
+// newRateLimitMiddleware 限流中间件实现，依次校验匹配的规则，任一规则拒绝时返回 http.StatusTooManyRequests。
+// 限流器出错时放行请求并记录错误日志
+func newRateLimitMiddleware(limiter ratelimit.Limiter, rules []rateLimitRule) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var (
+			tightest *ratelimit.Result // 剩余配额最少的结果，用于设置响应头
+			route    = routeOf(ctx)
+		)
+
+		for _, rule := range rules {
+			if !rule.match(ctx.Request.Method, route) {
+				continue
+			}
+
+			key := rule.keyFunc(ctx)
+			if key == "" {
+				continue
+			}
+
+			fields := erlogs.OptionFields(zap.String("rule", rule.Name), zap.String("key", key))
+			res, err := limiter.Allow(ctx, fmt.Sprintf("https:%s:%s", rule.Name, key), rule.limit)
+			if err != nil {
+				recordLog(ctx, erlogs.Convert(err).Wrap("rate limit failed").Options(BaseELOptions()).Erorr(fields))
+				continue
+			}
+
+			if !res.Allowed {
+				setRateLimitHeaders(ctx, res)
+				ctx.Header(HeaderKeyRetryAfter, strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+
+				// 按路由限流时所有客户端共享配额，返回请求次数过多；否则返回操作频率过快。
+				// 状态为全局变量，复制后再附加日志字段，日志由响应时记录
+				status := aob.VarOrVar(rule.Key == RateLimitKeyRoute, erlogs.TooManyRequests, erlogs.FrequencyLimit)
+				ResponseErrorWithStatus(ctx, http.StatusTooManyRequests, status.Clone().Warn(fields))
+				return
+			}
+
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				tightest = &res
+			}
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(ctx, *tightest)
+		}
+
+		ctx.Next()
+	}
+}
+
+// match 判断请求方法和路由是否匹配规则
+func (r rateLimitRule) match(method, route string) bool {
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	}) {
+		return false
+	}
+
+	if len(r.Routes) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(r.Routes, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(route, prefix)
+		}
+		return route == pattern
+	})
+}
+
+// routeOf 返回gin注册的完整路径，未匹配到路由时返回请求路径
+func routeOf(ctx *gin.Context) string {
+	if route := ctx.FullPath(); route != "" {
+		return route
+	}
+	return ctx.Request.URL.Path
+}
+
+// setRateLimitHeaders 设置配额上限、剩余配额和配额完全恢复的秒数
+func setRateLimitHeaders(ctx *gin.Context, res ratelimit.Result) {
+	ctx.Header(HeaderKeyRateLimitLimit, strconv.FormatInt(res.Limit, 10))
+	ctx.Header(HeaderKeyRateLimitRemaining, strconv.FormatInt(res.Remaining, 10))
+	ctx.Header(HeaderKeyRateLimitReset, strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+}
+
+// ceilSeconds 向上取整到秒
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package https
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/ratelimit"
+)
+
+const testDeviceHeader = "X-Test-Device"
+
+type errLimiter struct{}
+
+func (errLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("limiter down")
+}
+
+// stubRecordLog 替换日志记录，返回记录的日志数量
+func stubRecordLog(t *testing.T) func() int {
+	t.Helper()
+
+	var (
+		mu sync.Mutex
+		n  int
+	)
+
+	orig := recordLog
+	recordLog = func(context.Context, erlogs.ErLogInterface) {
+		mu.Lock()
+		n++
+		mu.Unlock()
+	}
+	t.Cleanup(func() {
+		recordLog = orig
+	})
+
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+}
+
+func newRateLimitEngine(t *testing.T, limiter ratelimit.Limiter, rules ...RateLimitRule) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &Server{}
+	parsed := make([]rateLimitRule, 0, len(rules))
+	for i, v := range rules {
+		parsed = append(parsed, s.parseRateLimitRule(i, v))
+	}
+
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.Use(func(ctx *gin.Context) {
+		ctx.Set(ClientInfoContextKey, &ClientInfo{DeviceID: ctx.GetHeader(testDeviceHeader)})
+	}, newRateLimitMiddleware(limiter, parsed))
+
+	ok := func(ctx *gin.Context) { ctx.String(http.StatusOK, "ok") }
+	engine.POST("/login", ok)
+	engine.GET("/login", ok)
+	engine.GET("/api/:id", ok)
+
+	return engine
+}
+
+func serve(engine *gin.Engine, method, path, ip string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	logs := stubRecordLog(t)
+	engine := newRateLimitEngine(t, ratelimit.NewLocal(),
+		RateLimitRule{Name: "login", Routes: []string{"/login"}, Methods: []string{"post"}, Key: RateLimitKeyIP, Rate: 2, Period: "1m"},
+		RateLimitRule{Name: "api", Routes: []string{"/api/*"}, Rate: 1, Period: "1m"},
+	)
+
+	for i, remaining := range []string{"1", "0"} {
+		w := serve(engine, http.MethodPost, "/login", "10.0.0.1", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, w.Code)
+		}
+		if w.Header().Get(HeaderKeyRateLimitLimit) != "2" || w.Header().Get(HeaderKeyRateLimitRemaining) != remaining {
+			t.Fatalf("request %d: headers = %v", i, w.Header())
+		}
+	}
+
+	// 伪造 X-Forwarded-For 不能绕过按IP限流
+	w := serve(engine, http.MethodPost, "/login", "10.0.0.1", map[string]string{ForwardedForHeader: "1.2.3.4"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get(HeaderKeyRetryAfter) == "" || w.Header().Get(HeaderKeyRateLimitRemaining) != "0" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+
+	var rsp ResponseData
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Code != erlogs.FrequencyLimit.GetCode() {
+		t.Fatalf("code = %d, want %d", rsp.Code, erlogs.FrequencyLimit.GetCode())
+	}
+	if logs() != 1 {
+		t.Fatalf("logs = %d, want 1", logs())
+	}
+
+	// 其他IP、不匹配的请求方法不受影响
+	if w = serve(engine, http.MethodPost, "/login", "10.0.0.2", nil); w.Code != http.StatusOK {
+		t.Fatalf("other ip: status = %d", w.Code)
+	}
+	if w = serve(engine, http.MethodGet, "/login", "10.0.0.1", nil); w.Code != http.StatusOK || w.Header().Get(HeaderKeyRateLimitLimit) != "" {
+		t.Fatalf("unmatched method: status = %d, headers = %v", w.Code, w.Header())
+	}
+
+	// 按路由限流时所有客户端共享配额
+	if w = serve(engine, http.MethodGet, "/api/1", "10.0.0.1", nil); w.Code != http.StatusOK {
+		t.Fatalf("route: status = %d", w.Code)
+	}
+	w = serve(engine, http.MethodGet, "/api/2", "10.0.0.2", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("route: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Code != erlogs.TooManyRequests.GetCode() {
+		t.Fatalf("route: code = %d, err = %v", rsp.Code, err)
+	}
+}
+
+func TestRateLimitMiddlewareDevice(t *testing.T) {
+	stubRecordLog(t)
+	engine := newRateLimitEngine(t, ratelimit.NewLocal(),
+		RateLimitRule{Key: RateLimitKeyDevice, Rate: 1, Period: "1m"},
+	)
+
+	device := map[string]string{testDeviceHeader: "d1"}
+	if w := serve(engine, http.MethodGet, "/login", "10.0.0.1", device); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if w := serve(engine, http.MethodGet, "/login", "10.0.0.2", device); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("same device: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	// 无设备ID时按客户端IP限流
+	if w := serve(engine, http.MethodGet, "/login", "10.0.0.3", nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if w := serve(engine, http.MethodGet, "/login", "10.0.0.3", map[string]string{ForwardedForHeader: "5.6.7.8"}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("ip fallback: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitMiddlewareFailOpen(t *testing.T) {
+	logs := stubRecordLog(t)
+	engine := newRateLimitEngine(t, errLimiter{}, RateLimitRule{Rate: 1})
+
+	for range 3 {
+		if w := serve(engine, http.MethodGet, "/login", "10.0.0.1", nil); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+	}
+
+	if logs() != 3 {
+		t.Fatalf("logs = %d, want 3", logs())
+	}
+}
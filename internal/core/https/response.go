@@ -1,6 +1,7 @@
 package https
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -36,6 +37,13 @@ type (
 	ResponseOption func(rsp *ResponseData)
 )
 
+var (
+	// recordLog 记录日志，测试时替换以避免依赖应用元数据
+	recordLog = func(ctx context.Context, el erlogs.ErLogInterface) {
+		el.RecordLog(ctx)
+	}
+)
+
 func NewResponseData(opts ...ResponseOption) *ResponseData {
 	rsp := &ResponseData{Code: ResponseSuccessCode, Type: ResponseTypeJSON}
 	for _, opt := range opts {
@@ -132,10 +140,8 @@ func ResponseSuccess(ctx *gin.Context, data any, opts ...ResponseOption) {
 
 // ResponseError 响应错误
 func ResponseError(ctx *gin.Context, err error, opts ...ResponseOption) {
-	el := erlogs.Convert(err)
-
 	var status int
-	switch el.GetCode() {
+	switch erlogs.Convert(err).GetCode() {
 	case ResponseSuccessCode:
 		status = http.StatusOK
 	case ResponseUnknownCode:
@@ -144,6 +150,13 @@ func ResponseError(ctx *gin.Context, err error, opts ...ResponseOption) {
 		status = http.StatusBadRequest
 	}
 
+	ResponseErrorWithStatus(ctx, status, err, opts...)
+}
+
+// ResponseErrorWithStatus 指定http status响应错误，响应体与 ResponseError 相同
+func ResponseErrorWithStatus(ctx *gin.Context, status int, err error, opts ...ResponseOption) {
+	el := erlogs.Convert(err)
+
 	ResponseWithStatus(ctx, status, append([]ResponseOption{
 		func(rsp *ResponseData) {
 			rsp.Msg = el.GetMsg()
@@ -151,7 +164,7 @@ func ResponseError(ctx *gin.Context, err error, opts ...ResponseOption) {
 			if rsp.Code == ResponseSuccessCode {
 				rsp.Code = erlogs.ServerError.GetCode()
 			}
-			el.RecordLog(ctx.Request.Context())
+			recordLog(ctx.Request.Context(), el)
 		},
 	}, opts...)...)
 }
@@ -65,6 +65,9 @@ func (s *Server) initServer() {
 	//use cors middleware
 	s.engine.Use(s.setupCORSMiddleware())
 
+	// use rate limit middleware
+	s.engine.Use(s.setupRateLimitMiddleware())
+
 	// use csrf middleware
 	s.engine.Use(s.setupCSRFMiddleware())
 
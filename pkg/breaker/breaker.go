@@ -0,0 +1,258 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"go.uber.org/zap"
+)
+
+const (
+	StateClosed   State = iota // 关闭，请求正常通过
+	StateOpen                  // 打开，请求直接返回 ErrCircuitOpen
+	StateHalfOpen              // 半开，放行少量探测请求，成功后关闭，失败后重新打开
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+
+	registry sync.Map // name -> *Breaker
+)
+
+type (
+	// State 熔断器状态
+	State int
+
+	// Breaker 基于滑动窗口的熔断器，按错误率、连续失败次数和慢调用比例判断是否打开
+	Breaker struct {
+		name string
+		options
+		mu          sync.Mutex
+		state       State
+		generation  uint64    // 状态变化时递增，忽略旧状态下放行请求的结果
+		changedAt   time.Time // 最近一次状态变化的时间
+		consecutive uint32    // 连续失败次数
+		halfOpen    uint32    // 半开状态下已放行的探测请求数
+		successes   uint32    // 半开状态下探测成功的请求数
+		window      *window   // 关闭状态下的滑动窗口统计
+	}
+)
+
+// Get 从注册表获取名为name的熔断器，不存在时使用opts创建并注册；已存在时忽略opts
+func Get(name string, opts ...Option) *Breaker {
+	if v, ok := registry.Load(name); ok {
+		return v.(*Breaker)
+	}
+
+	v, _ := registry.LoadOrStore(name, New(name, opts...))
+	return v.(*Breaker)
+}
+
+// Lookup 从注册表获取名为name的熔断器
+func Lookup(name string) (b *Breaker, ok bool) {
+	v, ok := registry.Load(name)
+	if !ok {
+		return
+	}
+	return v.(*Breaker), true
+}
+
+// New 创建熔断器，不加入注册表，需要跨调用方共享时使用 Get
+func New(name string, opts ...Option) *Breaker {
+	o := defaultOptions()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	o.normalize()
+
+	return &Breaker{
+		name:      name,
+		options:   o,
+		state:     StateClosed,
+		changedAt: time.Now(),
+		window:    newWindow(o.window, o.buckets),
+	}
+}
+
+// Name 返回熔断器名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 返回熔断器当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh(context.Background(), time.Now())
+	return b.state
+}
+
+// Allow 判断请求是否可以通过，熔断器打开或半开状态探测请求已满时返回 ErrCircuitOpen。
+// 请求通过时需在请求结束后调用done记录结果，err为请求的错误（由IsFailure判断是否为失败），耗时从调用Allow开始计算
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refresh(context.Background(), now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.halfOpen >= b.halfOpenMaxCalls {
+			return nil, ErrCircuitOpen
+		}
+		b.halfOpen++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.record(generation, err, time.Since(now))
+		})
+	}, nil
+}
+
+// Do 通过熔断器执行fn，熔断器打开时不执行fn并返回 ErrCircuitOpen
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			done(fmt.Errorf("panic occurred: %v", r))
+			panic(r)
+		}
+		done(err)
+	}()
+
+	return fn(ctx)
+}
+
+// refresh 打开状态超过冷却时间后转为半开；半开状态超过冷却时间仍未得到结果时重新放行探测请求
+func (b *Breaker) refresh(ctx context.Context, now time.Time) {
+	if now.Sub(b.changedAt) < b.openTimeout {
+		return
+	}
+
+	switch b.state {
+	case StateOpen, StateHalfOpen:
+		b.setState(ctx, StateHalfOpen, now)
+	}
+}
+
+// record 记录请求结果并判断是否需要转换状态
+func (b *Breaker) record(generation uint64, err error, elapsed time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 状态已变化，结果不再有效
+	if generation != b.generation {
+		return
+	}
+
+	ctx, now := context.Background(), time.Now()
+	failure := err != nil && b.isFailure(err)
+	slow := b.slowCallDuration > 0 && elapsed >= b.slowCallDuration
+
+	switch b.state {
+	case StateClosed:
+		b.window.add(now, failure, slow)
+		if failure {
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+
+		if b.shouldTrip(now) {
+			b.setState(ctx, StateOpen, now)
+		}
+	case StateHalfOpen:
+		if failure || slow {
+			b.setState(ctx, StateOpen, now)
+			return
+		}
+
+		if b.successes++; b.successes >= b.halfOpenMaxCalls {
+			b.setState(ctx, StateClosed, now)
+		}
+	}
+}
+
+// shouldTrip 判断关闭状态下是否需要打开熔断器
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	if b.consecutiveFailures > 0 && b.consecutive >= b.consecutiveFailures {
+		return true
+	}
+
+	total, failures, slows := b.window.sum(now)
+	if total == 0 || total < b.minRequests {
+		return false
+	}
+
+	if b.errorRate > 0 && float64(failures)/float64(total) >= b.errorRate {
+		return true
+	}
+
+	return b.slowCallDuration > 0 && b.slowCallRate > 0 && float64(slows)/float64(total) >= b.slowCallRate
+}
+
+// setState 转换状态，重置对应的统计并记录日志
+func (b *Breaker) setState(ctx context.Context, state State, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.changedAt = now
+	b.consecutive = 0
+	b.halfOpen = 0
+	b.successes = 0
+
+	if state == StateClosed {
+		b.window.reset()
+	}
+
+	if from != state {
+		logStateChange(ctx, b, from, state)
+		if b.onStateChange != nil {
+			b.onStateChange(b.name, from, state)
+		}
+	}
+}
+
+// logStateChange 通过erlogs记录状态变化，打开时记录警告日志
+var logStateChange = func(ctx context.Context, b *Breaker, from, to State) {
+	el := erlogs.Newf("circuit breaker %s state changed from %s to %s", b.name, from, to).
+		Options([]erlogs.Option{erlogs.OptionKindSystem()})
+	fields := erlogs.OptionFields(zap.String("breaker", b.name), zap.String("from", from.String()), zap.String("to", to.String()))
+
+	if to == StateOpen {
+		el.WarnLog(ctx, fields)
+		return
+	}
+
+	el.InfoLog(ctx, fields)
+}
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
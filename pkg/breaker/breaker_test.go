@@ -0,0 +1,97 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func init() {
+	// 测试环境未初始化元数据，不记录日志
+	logStateChange = func(ctx context.Context, b *Breaker, from, to State) {}
+}
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	var changes []State
+	b := New("consecutive", ConsecutiveFailures(3), OpenTimeout(50*time.Millisecond),
+		OnStateChange(func(name string, from, to State) { changes = append(changes, to) }))
+
+	for range 3 {
+		_ = b.Do(ctx, func(ctx context.Context) error { return failed })
+	}
+
+	if err := b.Do(ctx, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do while open = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// 冷却后半开，只放行一个探测请求
+	time.Sleep(60 * time.Millisecond)
+	done, err := b.Allow()
+	if err != nil || b.State() != StateHalfOpen {
+		t.Fatalf("Allow after open timeout: %v, state = %s", err, b.State())
+	}
+	if _, err = b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second half-open Allow = %v", err)
+	}
+
+	done(nil)
+	if b.State() != StateClosed {
+		t.Fatalf("state after probe success = %s", b.State())
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateClosed}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] || changes[2] != want[2] {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+}
+
+func TestBreakerRates(t *testing.T) {
+	ctx := context.Background()
+
+	// 错误率达到阈值时打开
+	b := New("error-rate", ConsecutiveFailures(0), MinRequests(10), ErrorRate(0.5))
+	for i := range 10 {
+		_ = b.Do(ctx, func(ctx context.Context) error {
+			if i%2 == 0 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after 50%% errors", b.State())
+	}
+
+	// 调用方取消不计为失败
+	b = New("canceled", ConsecutiveFailures(1))
+	_ = b.Do(ctx, func(ctx context.Context) error { return context.Canceled })
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s after canceled call", b.State())
+	}
+
+	// 慢调用比例达到阈值时打开
+	b = New("slow", MinRequests(2), SlowCall(10*time.Millisecond, 0.5))
+	for range 2 {
+		_ = b.Do(ctx, func(ctx context.Context) error {
+			time.Sleep(15 * time.Millisecond)
+			return nil
+		})
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after slow calls", b.State())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	b := Get("registry", ConsecutiveFailures(1))
+	if Get("registry") != b {
+		t.Fatal("Get returned a different breaker for the same name")
+	}
+	if v, ok := Lookup("registry"); !ok || v != b {
+		t.Fatal("Lookup failed")
+	}
+}
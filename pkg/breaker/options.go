@@ -0,0 +1,143 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	WindowDefault              = 10 * time.Second // 滑动窗口时长
+	BucketsDefault             = 10               // 滑动窗口分桶数
+	MinRequestsDefault         = 20               // 计算错误率和慢调用比例的最少请求数
+	ErrorRateDefault           = 0.5              // 打开熔断器的错误率
+	ConsecutiveFailuresDefault = 5                // 打开熔断器的连续失败次数
+	SlowCallRateDefault        = 0.5              // 打开熔断器的慢调用比例
+	OpenTimeoutDefault         = 5 * time.Second  // 打开状态转为半开的冷却时间
+	HalfOpenMaxCallsDefault    = 1                // 半开状态下放行的探测请求数
+)
+
+type (
+	// Option 熔断器配置
+	Option func(o *options)
+
+	options struct {
+		window              time.Duration                     // 滑动窗口时长
+		buckets             int                               // 滑动窗口分桶数
+		minRequests         uint32                            // 计算错误率和慢调用比例的最少请求数
+		errorRate           float64                           // 打开熔断器的错误率，0表示不启用
+		consecutiveFailures uint32                            // 打开熔断器的连续失败次数，0表示不启用
+		slowCallDuration    time.Duration                     // 慢调用耗时，0表示不启用
+		slowCallRate        float64                           // 打开熔断器的慢调用比例
+		openTimeout         time.Duration                     // 打开状态转为半开的冷却时间
+		halfOpenMaxCalls    uint32                            // 半开状态下放行的探测请求数，全部成功后关闭
+		isFailure           func(err error) bool              // 判断错误是否计为失败
+		onStateChange       func(name string, from, to State) // 状态变化回调
+	}
+)
+
+func defaultOptions() options {
+	return options{
+		window:              WindowDefault,
+		buckets:             BucketsDefault,
+		minRequests:         MinRequestsDefault,
+		errorRate:           ErrorRateDefault,
+		consecutiveFailures: ConsecutiveFailuresDefault,
+		slowCallRate:        SlowCallRateDefault,
+		openTimeout:         OpenTimeoutDefault,
+		halfOpenMaxCalls:    HalfOpenMaxCallsDefault,
+		isFailure:           IsFailure,
+	}
+}
+
+func (o *options) normalize() {
+	if o.window <= 0 {
+		o.window = WindowDefault
+	}
+
+	if o.buckets <= 0 {
+		o.buckets = BucketsDefault
+	}
+
+	if o.openTimeout <= 0 {
+		o.openTimeout = OpenTimeoutDefault
+	}
+
+	if o.halfOpenMaxCalls == 0 {
+		o.halfOpenMaxCalls = HalfOpenMaxCallsDefault
+	}
+
+	if o.isFailure == nil {
+		o.isFailure = IsFailure
+	}
+}
+
+// IsFailure 默认的失败判断，调用方取消（context.Canceled）不计为失败
+func IsFailure(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+// Window 配置滑动窗口时长和分桶数，默认10秒、10个分桶
+func Window(d time.Duration, buckets int) Option {
+	return func(o *options) {
+		o.window = d
+		o.buckets = buckets
+	}
+}
+
+// MinRequests 配置窗口内计算错误率和慢调用比例的最少请求数，默认20
+func MinRequests(n uint32) Option {
+	return func(o *options) {
+		o.minRequests = n
+	}
+}
+
+// ErrorRate 配置打开熔断器的错误率，取值(0, 1]，默认0.5，0表示不启用
+func ErrorRate(rate float64) Option {
+	return func(o *options) {
+		o.errorRate = rate
+	}
+}
+
+// ConsecutiveFailures 配置打开熔断器的连续失败次数，默认5，0表示不启用
+func ConsecutiveFailures(n uint32) Option {
+	return func(o *options) {
+		o.consecutiveFailures = n
+	}
+}
+
+// SlowCall 配置慢调用耗时和打开熔断器的慢调用比例，默认不启用；半开状态下的慢调用视为失败
+func SlowCall(d time.Duration, rate float64) Option {
+	return func(o *options) {
+		o.slowCallDuration = d
+		o.slowCallRate = rate
+	}
+}
+
+// OpenTimeout 配置打开状态转为半开的冷却时间，默认5秒
+func OpenTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.openTimeout = d
+	}
+}
+
+// HalfOpenMaxCalls 配置半开状态下放行的探测请求数，全部成功后关闭熔断器，默认1
+func HalfOpenMaxCalls(n uint32) Option {
+	return func(o *options) {
+		o.halfOpenMaxCalls = n
+	}
+}
+
+// FailureIf 配置判断错误是否计为失败的函数，默认为 IsFailure
+func FailureIf(fn func(err error) bool) Option {
+	return func(o *options) {
+		o.isFailure = fn
+	}
+}
+
+// OnStateChange 配置状态变化回调，在记录日志后调用，回调中不可调用熔断器的方法
+func OnStateChange(fn func(name string, from, to State)) Option {
+	return func(o *options) {
+		o.onStateChange = fn
+	}
+}
@@ -0,0 +1,60 @@
+package breaker
+
+import "time"
+
+type (
+	// window 分桶的滑动窗口，记录窗口内的请求数、失败数和慢调用数
+	window struct {
+		size    time.Duration // 每个分桶的时长
+		buckets []bucket
+	}
+
+	bucket struct {
+		index    int64 // 分桶对应的时间序号，与当前序号相差超过分桶数时已过期
+		total    uint32
+		failures uint32
+		slows    uint32
+	}
+)
+
+func newWindow(d time.Duration, buckets int) *window {
+	return &window{
+		size:    max(d/time.Duration(buckets), time.Millisecond),
+		buckets: make([]bucket, buckets),
+	}
+}
+
+// add 记录一次请求结果
+func (w *window) add(now time.Time, failure, slow bool) {
+	index := now.UnixNano() / int64(w.size)
+	b := &w.buckets[index%int64(len(w.buckets))]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+
+	b.total++
+	if failure {
+		b.failures++
+	}
+	if slow {
+		b.slows++
+	}
+}
+
+// sum 汇总窗口内未过期分桶的统计
+func (w *window) sum(now time.Time) (total, failures, slows uint32) {
+	index := now.UnixNano() / int64(w.size)
+	for _, b := range w.buckets {
+		if index-b.index < int64(len(w.buckets)) {
+			total += b.total
+			failures += b.failures
+			slows += b.slows
+		}
+	}
+	return
+}
+
+// reset 清空窗口
+func (w *window) reset() {
+	clear(w.buckets)
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/mel0dys0ng/song/pkg/result"
 	"github.com/mel0dys0ng/song/pkg/retry"
@@ -16,12 +17,36 @@ const (
 type (
 	// Cache 多级缓存结构体，支持LRU和Redis缓存
 	Cache[T any] struct {
-		keyPrefix  string
-		retryConf  retryConf
-		lruCache   *lruCache[T]
-		redisCache *redisCache[T]
-		isZero     func(data T) bool
-		dataId     func(data T) any
+		keyPrefix    string
+		retryConf    retryConf
+		lruCache     *lruCache[T]
+		redisCache   *redisCache[T]
+		isZero       func(data T) bool
+		dataId       func(data T) any
+		softTTL      time.Duration    // 软过期时间，0表示不启用
+		negativeTTL  time.Duration    // 负缓存（墓碑标记）过期时间，0表示不启用
+		invalidation invalidationConf // 跨实例LRU缓存失效配置
+		codec        codecConf        // Redis缓存数据编解码配置
+		cluster      *bool            // Redis集群模式，nil表示根据客户端类型自动判断
+		statsHook    StatsHook        // 统计事件钩子
+		stats        *stats           // 统计计数器
+		fill         fillConf         // 跨实例回源去重配置
+		write        writeConf[T]     // 写入配置
+		lruConf      lruConf[T]       // LRU缓存内存上限和淘汰回调配置
+	}
+
+	// lruConf LRU缓存内存上限和淘汰回调配置
+	lruConf[T any] struct {
+		maxCost  int64
+		costFunc CostFunc[T]
+		onEvict  EvictFunc[T]
+	}
+
+	// codecConf Redis缓存数据编解码配置
+	codecConf struct {
+		codec      Codec
+		compressor Compressor
+		threshold  int
 	}
 
 	Option[T any] = func(c *Cache[T])
@@ -37,13 +62,29 @@ type (
 		getType() string
 		isRetryEnable() bool
 		genKey(prefix string, data ...any) string
-		set(ctx context.Context, key, name any, prefix string, id any, value T) result.Interface[T]
+		set(ctx context.Context, key, name any, prefix string, id any, value T, tags ...string) result.Interface[T]
 		get(ctx context.Context, key any, prefix string) result.Interface[T]
 		del(ctx context.Context, key any, prefix string) result.Interface[T]
+		setTombstone(ctx context.Context, key any, prefix string, ttl time.Duration) result.Interface[T]
+		setTombstones(ctx context.Context, keys []any, prefix string, ttl time.Duration) result.Interface[int]
+		invalidateTag(ctx context.Context, tag string, prefix string) result.Interface[int]
+		getMany(ctx context.Context, keys []any, prefix string) result.Interface[map[any]result.Interface[T]]
+		setMany(ctx context.Context, name any, prefix string, items []item[T]) result.Interface[map[any]T]
+	}
+
+	// item 批量写入缓存的数据项
+	item[T any] struct {
+		key   any
+		id    any
+		value T
+		tags  []string
 	}
 
 	// SetFunc 缓存未命中时用于生成数据的函数类型
 	SetFunc[T any] func(ctx context.Context) result.Interface[T]
+
+	// SetManyFunc 批量缓存未命中时用于生成数据的函数类型，keys为未命中的缓存键，返回 key->data 映射
+	SetManyFunc[T any] func(ctx context.Context, keys []any) result.Interface[map[any]T]
 )
 
 /*
@@ -51,6 +92,8 @@ New 返回缓存对象.
 RedisCache和LRUCache至少设置一个，没设置则不支持对应类型的缓存.
 Retry 设置重试和singleflight.
 LRUCache不支持retry和singleflight.
+同时设置RedisCache和LRUCache时可通过Invalidation开启跨实例LRU缓存失效通知，开启后不再使用时需调用Close停止订阅.
+WriteBehind模式下Set异步持久化，不再使用时需调用Close持久化队列中剩余的数据.
 */
 func New[T any](opts ...Option[T]) (c *Cache[T]) {
 	c = &Cache[T]{retryConf: retryConf{enable: true}}
@@ -64,6 +107,29 @@ func New[T any](opts ...Option[T]) (c *Cache[T]) {
 		sys.Panic("isZero or dataId func is nil")
 	}
 
+	c.stats = newStats(c.statsHook)
+
+	if c.lruCache != nil {
+		c.lruCache.softTTL = c.softTTL
+		c.lruCache.stats = c.stats
+		c.lruCache.maxCost = c.lruConf.maxCost
+		c.lruCache.costFunc = c.lruConf.costFunc
+		c.lruCache.codec = newCodec(c.codec.codec, nil, 0)
+		c.lruCache.onEvict = c.lruConf.onEvict
+	}
+
+	if c.redisCache != nil {
+		c.redisCache.softTTL = c.softTTL
+		c.redisCache.codec = newCodec(c.codec.codec, c.codec.compressor, c.codec.threshold)
+		if c.cluster != nil {
+			c.redisCache.cluster = *c.cluster
+		}
+	}
+
+	c.startInvalidation()
+	c.startFill()
+	c.startWrite()
+
 	return
 }
 
@@ -75,16 +141,24 @@ func New[T any](opts ...Option[T]) (c *Cache[T]) {
 //   - 2）若lru和redis cache仅开启一个，缓存数据不存在，则使用源数据更新缓存数据。
 //   - 3）retry默认开启，可关闭可配置。
 //   - 4）singleflight默认关闭，可配置，开启之后singleflight key为缓存key。
+//   - 5）配置SoftTTL后，数据超过软过期时间时直接返回旧数据，并在后台回源刷新（通过retry的singleflight按key合并），
+//     可通过 IsStale/IsRefreshing 判断返回结果的状态；超过硬过期时间（LRUCache/RedisCache的ttl）的数据仍会被淘汰。
+//   - 6）配置NegativeTTL后，set函数返回零值时在各级缓存写入墓碑标记，墓碑有效期内直接返回零值而不回源，
+//     可通过 IsNegative 判断返回结果是否命中负缓存。
+//   - 7）写入缓存时为数据打上tags，可通过 InvalidateTag 删除打上该tag的所有数据。
+//   - 8）配置DistributedFill后，多级缓存均未命中时通过分布式锁保证同一时间只有一个实例回源，
+//     其他实例轮询Redis等待回源结果，等待超时后自行回源。
 //
 // 参数:
 //   - ctx: 上下文，用于控制请求生命周期，如超时或取消
 //   - name: 缓存名称，用于区分不同业务
 //   - key: 缓存键，支持任意类型
 //   - set: 缓存未命中时用于生成数据的函数
+//   - tags: 数据的标签，每次调用应传入相同的标签
 //
 // 返回值:
 //   - result.Interface[T]: 包含获取结果或错误信息的包装对象
-func (c *Cache[T]) GetOrSet(ctx context.Context, name, key any, set SetFunc[T]) (res result.Interface[T]) {
+func (c *Cache[T]) GetOrSet(ctx context.Context, name, key any, set SetFunc[T], tags ...string) (res result.Interface[T]) {
 	// 初始化错误结果和缓存未命中标记
 	res = result.Error[T](nil)
 	isLRUCacheNotFound, isRedisCacheNotFound := false, false
@@ -102,6 +176,7 @@ func (c *Cache[T]) GetOrSet(ctx context.Context, name, key any, set SetFunc[T])
 		}
 
 		// 根据重试配置选择执行方式
+		start := time.Now()
 		if cache.isRetryEnable() {
 			res = retryDo(ctx, &retryDoRequest[T, T]{
 				key:          cache.genKey(c.keyPrefix, key, name, "getorset"),
@@ -113,14 +188,32 @@ func (c *Cache[T]) GetOrSet(ctx context.Context, name, key any, set SetFunc[T])
 			res = handler(ctx)
 		}
 
+		// 记录命中统计，命中负缓存也视为命中
+		if res.Err() == nil && (IsNegative(res) || !c.isZero(res.Data())) {
+			c.stats.observeGet(ctx, cache.getType(), 1, 0, nil, time.Since(start))
+		} else {
+			c.stats.observeGet(ctx, cache.getType(), 0, 1, res.Err(), time.Since(start))
+		}
+
+		// 命中负缓存（墓碑标记），直接返回零值，不再回源
+		if res.Err() == nil && IsNegative(res) {
+			return
+		}
+
 		// 成功获取有效数据时的处理
 		if res.Err() == nil && !c.isZero(res.Data()) {
+			// 数据超过软过期时间，返回旧数据并后台刷新
+			if IsStale(res) {
+				return c.refresh(ctx, cache, name, key, set, res.Data(), tags)
+			}
+
 			// 当从Redis获取到数据且LRU缓存未命中时，异步更新LRU缓存
 			if c.isRedisCache(cache) && c.lruCache.isSet() && isLRUCacheNotFound {
 				_ = c.set(ctx, []cacheInterface[T]{c.lruCache}, name, key,
 					func(ctx context.Context) result.Interface[T] {
 						return res
 					},
+					tags...,
 				)
 			}
 			return
@@ -136,20 +229,68 @@ func (c *Cache[T]) GetOrSet(ctx context.Context, name, key any, set SetFunc[T])
 
 	// 多级缓存均未命中时的回源处理
 	if c.isZero(res.Data()) || (isLRUCacheNotFound || isRedisCacheNotFound) {
-		return c.set(ctx, []cacheInterface[T]{c.lruCache, c.redisCache}, name, key, set)
+		c.stats.observeMiss(ctx, 1)
+		if c.fill.enable {
+			return c.distributedFill(ctx, name, key, set, tags)
+		}
+		return c.set(ctx, []cacheInterface[T]{c.lruCache, c.redisCache}, name, key, c.loader(set), tags...)
 	}
 
 	return
 }
 
+// refresh 返回超过软过期时间的旧数据，同时在后台回源刷新各级缓存。
+// 刷新通过retry的singleflight按key合并，并发读取到旧数据时只回源一次；回源数据为零值且未开启负缓存时删除缓存，避免持续返回旧数据
+func (c *Cache[T]) refresh(ctx context.Context, cache cacheInterface[T], name, key any, set SetFunc[T], stale T, tags []string) result.Interface[T] {
+	refreshKey := cache.genKey(c.keyPrefix, key, name, "refresh")
+	go func(ctx context.Context) {
+		_ = retryDo(ctx, &retryDoRequest[T, T]{
+			key:          refreshKey,
+			singleflight: true,
+			cache:        c,
+			handler: func(ctx context.Context) result.Interface[T] {
+				setRes := c.set(ctx, []cacheInterface[T]{c.lruCache, c.redisCache}, name, key, c.loader(set), tags...)
+				if setRes.Err() != nil {
+					return setRes
+				}
+
+				if c.isZero(setRes.Data()) {
+					if c.negativeTTL <= 0 {
+						return c.Del(ctx, key)
+					}
+					c.publish(ctx, key)
+					return setRes
+				}
+
+				// 覆盖写，通知其他实例淘汰旧的LRU缓存
+				c.publish(ctx, key, c.dataId(setRes.Data()))
+				return setRes
+			},
+		})
+	}(context.WithoutCancel(ctx))
+
+	return &Result[T]{Interface: result.Success(stale), stale: true, refreshing: true}
+}
+
+// loader 包装回源函数，记录回源次数、失败次数和耗时
+func (c *Cache[T]) loader(set SetFunc[T]) SetFunc[T] {
+	return func(ctx context.Context) result.Interface[T] {
+		start := time.Now()
+		res := set(ctx)
+		c.stats.observeLoad(ctx, res.Err(), time.Since(start))
+		return res
+	}
+}
+
 // set 方法用于将数据设置到缓存链中的每个可用缓存节点
 // ctx: 上下文对象，用于控制请求生命周期
 // name: 缓存名称，用于区分不同业务
 // list: 缓存接口列表，包含多个层级的缓存实现
 // key: 缓存键值，用于标识存储的数据
 // set: 数据获取函数，当缓存未命中时用于获取源数据
+// tags: 数据的标签
 // 返回值: 缓存操作结果对象，包含数据或错误信息
-func (c *Cache[T]) set(ctx context.Context, list []cacheInterface[T], name, key any, set SetFunc[T]) (res result.Interface[T]) {
+func (c *Cache[T]) set(ctx context.Context, list []cacheInterface[T], name, key any, set SetFunc[T], tags ...string) (res result.Interface[T]) {
 	var setRes result.Interface[T] // 源数据获取结果缓存，避免重复获取
 
 	// 遍历缓存链中的每个缓存实现
@@ -163,15 +304,24 @@ func (c *Cache[T]) set(ctx context.Context, list []cacheInterface[T], name, key
 		handler := func(ctx context.Context) result.Interface[T] {
 			// 保证源数据只获取一次（首次需要时触发）
 			if setRes == nil {
-				// 执行源数据获取函数
 				setRes = set(ctx)
-				// 当数据有效时设置到当前缓存节点
-				if setRes.Err() == nil && !c.isZero(setRes.Data()) {
-					id := c.dataId(setRes.Data())
-					return cache.set(ctx, key, name, c.keyPrefix, id, setRes.Data())
+			}
+
+			if setRes.Err() != nil {
+				return setRes
+			}
+
+			// 源数据为零值时，开启负缓存则写入墓碑标记，否则不缓存
+			if c.isZero(setRes.Data()) {
+				if c.negativeTTL > 0 {
+					return cache.setTombstone(ctx, key, c.keyPrefix, c.negativeTTL)
 				}
+				return setRes
 			}
-			return setRes
+
+			// 当数据有效时设置到当前缓存节点
+			id := c.dataId(setRes.Data())
+			return cache.set(ctx, key, name, c.keyPrefix, id, setRes.Data(), tags...)
 		}
 
 		// 根据缓存配置选择执行策略
@@ -197,9 +347,229 @@ func (c *Cache[T]) set(ctx context.Context, list []cacheInterface[T], name, key
 	return
 }
 
-// Del 从多级缓存中删除指定键以及对于ID相关的键，支持LRU本地缓存和Redis远程缓存两级删除
+// GetOrSetMany 批量从多级缓存（LRU和Redis）中获取数据，未命中的键统一调用一次set函数回源获取并更新缓存
 //
-// 若开启lru和redis cache，则删除优先级: redis > lru
+//   - 1）按 lru > redis 的顺序逐级查询，每一级只查询上一级未命中的键；
+//   - 2）Redis 通过 pipeline 批量 MGET key->idNameKey 映射和数据，LRU 未命中而 Redis 命中的数据回写LRU；
+//   - 3）各级缓存均未命中的键调用一次set函数回源，回源数据在每一级缓存中批量写入（Redis为一次pipeline）；
+//   - 4）缓存键必须是可比较类型（作为map的键），重复的键只查询一次；
+//   - 5）写入缓存时为数据打上tags，可通过 InvalidateTag 删除打上该tag的所有数据；
+//   - 6）配置SoftTTL后，超过软过期时间的数据直接返回，并在后台对这些键调用一次set函数刷新各级缓存；
+//   - 7）配置NegativeTTL后，回源后仍不存在的键在各级缓存写入墓碑标记，墓碑有效期内不再回源。
+//
+// 参数:
+//   - ctx: 上下文，用于控制请求生命周期，如超时或取消
+//   - name: 缓存名称，用于区分不同业务
+//   - keys: 缓存键列表
+//   - set: 缓存未命中时用于批量生成数据的函数，参数为未命中的缓存键
+//   - tags: 数据的标签，每次调用应传入相同的标签
+//
+// 返回值:
+//   - result.Interface[map[any]T]: key->data 映射，不包含回源后仍不存在和命中负缓存的键；回源或写缓存失败时同时返回已获取的数据和错误
+func (c *Cache[T]) GetOrSetMany(ctx context.Context, name any, keys []any, set SetManyFunc[T], tags ...string) (res result.Interface[map[any]T]) {
+	data := make(map[any]T, len(keys))
+	missing := make([]any, 0, len(keys))
+	seen := make(map[any]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		missing = append(missing, key)
+	}
+
+	// 按缓存层级顺序批量获取（先LRU后Redis）
+	var (
+		backfill []item[T] // Redis命中而LRU未命中的数据，用于回写LRU
+		stale    []any     // 超过软过期时间的键，用于后台刷新
+	)
+	for _, cache := range []cacheInterface[T]{c.lruCache, c.redisCache} {
+		// 跳过nil或未启用的缓存组件，全部命中时提前结束
+		if cache == nil || !cache.isSet() || len(missing) == 0 {
+			continue
+		}
+
+		query := missing
+		handler := func(ctx context.Context) result.Interface[map[any]result.Interface[T]] {
+			return cache.getMany(ctx, query, c.keyPrefix)
+		}
+
+		var getRes result.Interface[map[any]result.Interface[T]]
+		start := time.Now()
+		if cache.isRetryEnable() {
+			getRes = retryDo(ctx, &retryDoRequest[map[any]result.Interface[T], T]{
+				key:          cache.genKey(c.keyPrefix, query, name, "getorsetmany"),
+				singleflight: c.retryConf.singleflight,
+				handler:      handler,
+				cache:        c,
+			})
+		} else {
+			getRes = handler(ctx)
+		}
+
+		// 查询失败时视为该级缓存全部未命中
+		if getRes.Err() != nil {
+			c.stats.observeGet(ctx, cache.getType(), 0, len(query), getRes.Err(), time.Since(start))
+			continue
+		}
+
+		missing = make([]any, 0, len(query))
+		for _, key := range query {
+			value, ok := getRes.Data()[key]
+
+			// 命中负缓存（墓碑标记）的键不返回数据，也不再回源
+			if ok && IsNegative(value) {
+				continue
+			}
+
+			if !ok || c.isZero(value.Data()) {
+				missing = append(missing, key)
+				continue
+			}
+
+			data[key] = value.Data()
+
+			// 旧数据不回写LRU，避免重置软过期时间，由后台刷新写入新数据
+			if IsStale(value) {
+				stale = append(stale, key)
+				continue
+			}
+
+			if c.isRedisCache(cache) && c.lruCache.isSet() {
+				backfill = append(backfill, item[T]{key: key, id: c.dataId(value.Data()), value: value.Data(), tags: tags})
+			}
+		}
+
+		c.stats.observeGet(ctx, cache.getType(), len(query)-len(missing), len(missing), nil, time.Since(start))
+	}
+
+	if len(backfill) > 0 {
+		_ = c.lruCache.setMany(ctx, name, c.keyPrefix, backfill)
+	}
+
+	if len(stale) > 0 {
+		c.refreshMany(ctx, name, stale, set, tags)
+	}
+
+	if len(missing) == 0 {
+		return result.Success(data)
+	}
+
+	// 多级缓存均未命中的键统一回源
+	c.stats.observeMiss(ctx, len(missing))
+	setRes := c.setMany(ctx, name, missing, set, tags)
+	for key, value := range setRes.Data() {
+		data[key] = value
+	}
+
+	return result.New(data, setRes.Err())
+}
+
+// refreshMany 在后台对超过软过期时间的键调用一次set函数，刷新各级缓存。
+// 刷新通过retry的singleflight按键列表合并；回源后仍不存在的键未开启负缓存时删除缓存，避免持续返回旧数据
+func (c *Cache[T]) refreshMany(ctx context.Context, name any, keys []any, set SetManyFunc[T], tags []string) {
+	key := c.redisCache.genKey(c.keyPrefix, keys, name, "refreshmany")
+	go func(ctx context.Context) {
+		_ = retryDo(ctx, &retryDoRequest[map[any]T, T]{
+			key:          key,
+			singleflight: true,
+			cache:        c,
+			handler: func(ctx context.Context) result.Interface[map[any]T] {
+				res := c.setMany(ctx, name, keys, set, tags)
+				if res.Err() != nil || c.negativeTTL > 0 {
+					return res
+				}
+
+				for _, key := range keys {
+					if _, ok := res.Data()[key]; ok {
+						continue
+					}
+					if err := c.Del(ctx, key).Err(); err != nil {
+						return result.New(res.Data(), err)
+					}
+				}
+
+				return res
+			},
+		})
+	}(context.WithoutCancel(ctx))
+}
+
+// setMany 调用一次set函数批量回源，并将回源数据批量写入每一级缓存，返回回源得到的非零值数据。
+// 开启负缓存时，回源后仍不存在的键在每一级缓存写入墓碑标记；写入后通过一条失效通知让其他实例淘汰这些键的LRU缓存
+func (c *Cache[T]) setMany(ctx context.Context, name any, keys []any, set SetManyFunc[T], tags []string) result.Interface[map[any]T] {
+	data := make(map[any]T, len(keys))
+
+	start := time.Now()
+	setRes := set(ctx, keys)
+	c.stats.observeLoad(ctx, setRes.Err(), time.Since(start))
+	if setRes.Err() != nil {
+		return result.New(data, setRes.Err())
+	}
+
+	items := make([]item[T], 0, len(keys))
+	var negatives []any // 回源后仍不存在的键，开启负缓存时写入墓碑标记
+	for _, key := range keys {
+		value, ok := setRes.Data()[key]
+		if !ok || c.isZero(value) {
+			if c.negativeTTL > 0 {
+				negatives = append(negatives, key)
+			}
+			continue
+		}
+		data[key] = value
+		items = append(items, item[T]{key: key, id: c.dataId(value), value: value, tags: tags})
+	}
+
+	if len(items) == 0 && len(negatives) == 0 {
+		return result.Success(data)
+	}
+
+	// 回源数据和墓碑标记写入每一级缓存
+	for _, cache := range []cacheInterface[T]{c.lruCache, c.redisCache} {
+		if cache == nil || !cache.isSet() {
+			continue
+		}
+
+		handler := func(ctx context.Context) result.Interface[map[any]T] {
+			if len(negatives) > 0 {
+				if res := cache.setTombstones(ctx, negatives, c.keyPrefix, c.negativeTTL); res.Err() != nil {
+					return result.Error[map[any]T](res.Err())
+				}
+			}
+			return cache.setMany(ctx, name, c.keyPrefix, items)
+		}
+
+		var setManyRes result.Interface[map[any]T]
+		if cache.isRetryEnable() {
+			setManyRes = retryDo(ctx, &retryDoRequest[map[any]T, T]{
+				key:          cache.genKey(c.keyPrefix, keys, name, "setmany"),
+				singleflight: false,
+				handler:      handler,
+				cache:        c,
+			})
+		} else {
+			setManyRes = handler(ctx)
+		}
+
+		if setManyRes.Err() != nil {
+			return result.New(data, setManyRes.Err())
+		}
+	}
+
+	// 覆盖写，通知其他实例淘汰旧的LRU缓存
+	published := make([]any, 0, len(items)*2+len(negatives))
+	for _, v := range items {
+		published = append(published, v.key, v.id)
+	}
+	c.publish(ctx, append(published, negatives...)...)
+
+	return result.Success(data)
+}
+
+// Del 从多级缓存中删除指定键以及对于ID相关的键，支持LRU本地缓存和Redis远程缓存两级删除，同时清除该键的墓碑标记
+//
+// 若开启lru和redis cache，则删除优先级: redis > lru，并通过Redis pub/sub通知其他实例淘汰本地LRU缓存
 // retry默认开启，可关闭可配置，配置默认为retry的默认配置
 //
 // 参数:
@@ -245,6 +615,13 @@ func (c *Cache[T]) Del(ctx context.Context, key any) (res result.Interface[T]) {
 		}
 	}
 
+	// 通知其他实例淘汰LRU缓存
+	keys := []any{key}
+	if !c.isZero(data) {
+		keys = append(keys, c.dataId(data))
+	}
+	c.publish(ctx, keys...)
+
 	// 最终返回最近一次成功操作获取的数据（如果有）
 	res.SetData(data)
 	return
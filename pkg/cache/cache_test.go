@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestCache 创建同时开启LRU和Redis的测试缓存，client为nil时使用新的miniredis
+func newTestCache(t *testing.T, client redis.UniversalClient, opts ...Option[benchUser]) *Cache[benchUser] {
+	t.Helper()
+
+	if client == nil {
+		client = redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+	}
+
+	c := New(append([]Option[benchUser]{
+		RedisCache[benchUser](client, time.Minute),
+		LRUCache[benchUser](100, time.Minute),
+		KeyPrefix[benchUser]("test:"),
+		IsZero(func(data benchUser) bool { return data.ID == 0 }),
+		DataId(func(data benchUser) any { return data.ID }),
+	}, opts...)...)
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+func TestGetOrSetMany(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil)
+	users := map[any]benchUser{"user:1": {ID: 1, Name: "a"}, "user:2": {ID: 2, Name: "b"}}
+
+	// user:1 仅存在于Redis
+	if res := c.redisCache.set(ctx, "user:1", "user", c.keyPrefix, int64(1), users["user:1"]); res.Err() != nil {
+		t.Fatalf("set: %v", res.Err())
+	}
+
+	var loaded [][]any
+	loader := func(ctx context.Context, keys []any) result.Interface[map[any]benchUser] {
+		loaded = append(loaded, keys)
+		data := make(map[any]benchUser, len(keys))
+		for _, key := range keys {
+			if v, ok := users[key]; ok {
+				data[key] = v
+			}
+		}
+		return result.Success(data)
+	}
+
+	// 重复的键只查询一次，回源仅包含多级缓存均未命中的键
+	res := c.GetOrSetMany(ctx, "user", []any{"user:1", "user:2", "user:2", "user:3"}, loader)
+	if res.Err() != nil || len(res.Data()) != 2 || res.Data()["user:1"] != users["user:1"] || res.Data()["user:2"] != users["user:2"] {
+		t.Fatalf("GetOrSetMany: %+v, %v", res.Data(), res.Err())
+	}
+
+	if len(loaded) != 1 || !slices.Equal(loaded[0], []any{"user:2", "user:3"}) {
+		t.Fatalf("loader keys: %v", loaded)
+	}
+
+	// Redis命中的数据回写LRU，回源数据写入每一级缓存
+	for key, user := range users {
+		if got := c.lruCache.get(ctx, key, c.keyPrefix); got.Data() != user {
+			t.Fatalf("lru get %v: %+v", key, got.Data())
+		}
+		if got := c.redisCache.get(ctx, key, c.keyPrefix); got.Err() != nil || got.Data() != user {
+			t.Fatalf("redis get %v: %+v, %v", key, got.Data(), got.Err())
+		}
+	}
+
+	loaded = nil
+	if res = c.GetOrSetMany(ctx, "user", []any{"user:1", "user:2", "user:3"}, loader); len(res.Data()) != 2 {
+		t.Fatalf("GetOrSetMany: %+v, %v", res.Data(), res.Err())
+	}
+
+	if len(loaded) != 1 || !slices.Equal(loaded[0], []any{"user:3"}) {
+		t.Fatalf("loader keys: %v", loaded)
+	}
+}
+
+func TestSetManyKeepsMapping(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil)
+	user := benchUser{ID: 1, Name: "a"}
+
+	for _, cache := range []cacheInterface[benchUser]{c.lruCache, c.redisCache} {
+		t.Run(cache.getType(), func(t *testing.T) {
+			if res := cache.set(ctx, "user:1", "user", c.keyPrefix, user.ID, user); res.Err() != nil {
+				t.Fatalf("set: %v", res.Err())
+			}
+
+			// 与set一致，已映射到其他数据的键沿用原映射，不被本次写入的ID覆盖
+			other := benchUser{ID: 2, Name: "b"}
+			items := []item[benchUser]{{key: "user:1", id: other.ID, value: other}, {key: "mobile:1", id: user.ID, value: user}}
+			if res := cache.setMany(ctx, "user", c.keyPrefix, items); res.Err() != nil || len(res.Data()) != 2 {
+				t.Fatalf("setMany: %+v, %v", res.Data(), res.Err())
+			}
+
+			got := cache.getMany(ctx, []any{"user:1", "mobile:1", user.ID}, c.keyPrefix)
+			if got.Err() != nil || len(got.Data()) != 3 || got.Data()["mobile:1"].Data() != user || got.Data()["user:1"].Data() != user {
+				t.Fatalf("getMany: %+v, %v", got.Data(), got.Err())
+			}
+
+			// 删除任一别名时，同一数据的所有映射一并删除
+			_ = cache.del(ctx, "mobile:1", c.keyPrefix)
+			if got = cache.getMany(ctx, []any{"user:1", user.ID}, c.keyPrefix); len(got.Data()) != 0 {
+				t.Fatalf("getMany after del: %+v", got.Data())
+			}
+		})
+	}
+}
+
+func TestSoftTTL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil, SoftTTL[benchUser](50*time.Millisecond))
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		n := calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return result.Success(benchUser{ID: 1, Name: strconv.Itoa(int(n))})
+	}
+
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); res.Err() != nil || res.Data().Name != "1" || IsStale(res) {
+		t.Fatalf("GetOrSet: %+v, %v", res.Data(), res.Err())
+	}
+
+	// 超过软过期时间后并发读取均返回旧数据，后台刷新合并为一次回源
+	time.Sleep(60 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := c.GetOrSet(ctx, "user", "user:1", loader); res.Data().Name != "1" || !IsStale(res) || !IsRefreshing(res) {
+				t.Errorf("GetOrSet stale: %+v, %v", res.Data(), res.Err())
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, func() bool {
+		return c.lruCache.get(ctx, "user:1", c.keyPrefix).Data().Name == "2"
+	})
+
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); res.Data().Name != "2" || IsStale(res) {
+		t.Fatalf("GetOrSet refreshed: %+v, %v", res.Data(), res.Err())
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("loader calls = %d, want 2", n)
+	}
+}
+
+func TestSoftTTLGetOrSetMany(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil, SoftTTL[benchUser](50*time.Millisecond))
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, keys []any) result.Interface[map[any]benchUser] {
+		n := calls.Add(1)
+		data := make(map[any]benchUser, len(keys))
+		for i, key := range keys {
+			data[key] = benchUser{ID: int64(i + 1), Name: strconv.Itoa(int(n))}
+		}
+		return result.Success(data)
+	}
+
+	keys := []any{"user:1", "user:2"}
+	if res := c.GetOrSetMany(ctx, "user", keys, loader); res.Err() != nil || len(res.Data()) != 2 {
+		t.Fatalf("GetOrSetMany: %+v, %v", res.Data(), res.Err())
+	}
+
+	// 超过软过期时间的数据直接返回，后台刷新
+	time.Sleep(60 * time.Millisecond)
+	if res := c.GetOrSetMany(ctx, "user", keys, loader); res.Data()["user:1"].Name != "1" {
+		t.Fatalf("GetOrSetMany stale: %+v, %v", res.Data(), res.Err())
+	}
+
+	waitFor(t, func() bool {
+		return c.redisCache.get(ctx, "user:2", c.keyPrefix).Data().Name == "2"
+	})
+
+	if res := c.GetOrSetMany(ctx, "user", keys, loader); res.Data()["user:1"].Name != "2" || res.Data()["user:2"].Name != "2" {
+		t.Fatalf("GetOrSetMany refreshed: %+v, %v", res.Data(), res.Err())
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("loader calls = %d, want 2", n)
+	}
+}
+
+// waitFor 等待后台任务完成，超时后测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetOrSetWritesAllLayers(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil)
+	user := benchUser{ID: 1, Name: "a"}
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		calls.Add(1)
+		return result.Success(user)
+	}
+
+	// 回源一次，回源数据写入每一级缓存
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); res.Err() != nil || res.Data() != user {
+		t.Fatalf("GetOrSet: %+v, %v", res.Data(), res.Err())
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("loader calls = %d, want 1", n)
+	}
+
+	for _, cache := range []cacheInterface[benchUser]{c.lruCache, c.redisCache} {
+		if res := cache.get(ctx, "user:1", c.keyPrefix); res.Err() != nil || res.Data() != user {
+			t.Fatalf("%s get: %+v, %v", cache.getType(), res.Data(), res.Err())
+		}
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	ttl := 50 * time.Millisecond
+	c := newTestCache(t, client, NegativeTTL[benchUser](ttl))
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		calls.Add(1)
+		return result.Success(benchUser{})
+	}
+
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); res.Err() != nil || IsNegative(res) {
+		t.Fatalf("GetOrSet: %+v, %v", res.Data(), res.Err())
+	}
+
+	// 墓碑有效期内命中负缓存，不再回源
+	for _, cache := range []cacheInterface[benchUser]{c.lruCache, c.redisCache} {
+		if res := cache.get(ctx, "user:1", c.keyPrefix); !IsNegative(res) {
+			t.Fatalf("%s get: %+v, %v, want negative", cache.getType(), res.Data(), res.Err())
+		}
+	}
+
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); !IsNegative(res) || calls.Load() != 1 {
+		t.Fatalf("GetOrSet: negative = %v, loader calls = %d", IsNegative(res), calls.Load())
+	}
+
+	// 墓碑过期后重新回源
+	time.Sleep(ttl)
+	mr.FastForward(ttl)
+	if res := c.GetOrSet(ctx, "user", "user:1", loader); IsNegative(res) || calls.Load() != 2 {
+		t.Fatalf("GetOrSet: negative = %v, loader calls = %d", IsNegative(res), calls.Load())
+	}
+}
+
+func TestNegativeTTLGetOrSetMany(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil, NegativeTTL[benchUser](time.Minute))
+	user := benchUser{ID: 1, Name: "a"}
+
+	var loaded [][]any
+	loader := func(ctx context.Context, keys []any) result.Interface[map[any]benchUser] {
+		loaded = append(loaded, keys)
+		return result.Success(map[any]benchUser{"user:1": user})
+	}
+
+	keys := []any{"user:1", "user:2"}
+	if res := c.GetOrSetMany(ctx, "user", keys, loader); res.Err() != nil || len(res.Data()) != 1 {
+		t.Fatalf("GetOrSetMany: %+v, %v", res.Data(), res.Err())
+	}
+
+	// 回源后仍不存在的键在每一级缓存写入墓碑标记
+	for _, cache := range []cacheInterface[benchUser]{c.lruCache, c.redisCache} {
+		res := cache.getMany(ctx, keys, c.keyPrefix)
+		if res.Err() != nil || res.Data()["user:1"].Data() != user || !IsNegative(res.Data()["user:2"]) {
+			t.Fatalf("%s getMany: %+v, %v", cache.getType(), res.Data(), res.Err())
+		}
+	}
+
+	if res := c.GetOrSetMany(ctx, "user", keys, loader); len(res.Data()) != 1 || len(loaded) != 1 {
+		t.Fatalf("GetOrSetMany: %+v, loader calls = %d", res.Data(), len(loaded))
+	}
+
+	// 单条读取同样命中批量写入的墓碑标记
+	if res := c.GetOrSet(ctx, "user", "user:2", func(ctx context.Context) result.Interface[benchUser] {
+		t.Fatal("loader called for a negative key")
+		return nil
+	}); !IsNegative(res) {
+		t.Fatalf("GetOrSet: %+v, want negative", res.Data())
+	}
+}
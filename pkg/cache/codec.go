@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	CodecIDJSON    byte = 1 // JSON编码
+	CodecIDGob     byte = 2 // gob编码
+	CodecIDMsgpack byte = 3 // msgpack编码
+
+	CompressorIDNone byte = 0 // 不压缩
+	CompressorIDGzip byte = 1 // gzip压缩
+	CompressorIDZstd byte = 2 // zstd压缩
+)
+
+var (
+	// CodecJSON JSON编码，默认编码
+	CodecJSON Codec = jsonCodec{}
+	// CodecGob gob编码，保留Go类型信息，T中的接口类型字段需要 gob.Register
+	CodecGob Codec = gobCodec{}
+	// CodecMsgpack msgpack编码，体积小、速度快
+	CodecMsgpack Codec = msgpackCodec{}
+
+	// CompressorGzip gzip压缩
+	CompressorGzip Compressor = gzipCompressor{}
+	// CompressorZstd zstd压缩
+	CompressorZstd Compressor = zstdCompressor{}
+
+	// builtinCodecs 内置编码，解码时根据数据头部的编码ID选择
+	builtinCodecs = map[byte]Codec{
+		CodecIDJSON:    CodecJSON,
+		CodecIDGob:     CodecGob,
+		CodecIDMsgpack: CodecMsgpack,
+	}
+
+	// builtinCompressors 内置压缩算法，解压时根据数据头部的压缩ID选择
+	builtinCompressors = map[byte]Compressor{
+		CompressorIDGzip: CompressorGzip,
+		CompressorIDZstd: CompressorZstd,
+	}
+
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+type (
+	// Codec Redis缓存数据的编码接口，ID取值范围为1~15，需在所有实例间保持唯一且不变
+	Codec interface {
+		ID() byte
+		Marshal(v any) ([]byte, error)
+		Unmarshal(data []byte, v any) error
+	}
+
+	// Compressor Redis缓存数据的压缩接口，ID取值范围为1~15，需在所有实例间保持唯一且不变
+	Compressor interface {
+		ID() byte
+		Compress(data []byte) ([]byte, error)
+		Decompress(data []byte) ([]byte, error)
+	}
+
+	// codec 带一字节头部的编解码器，头部高4位为压缩ID，低4位为编码ID。
+	// 解码时根据头部选择编码和压缩算法，因此更换编码或压缩配置后无需清空Redis
+	codec struct {
+		codec      Codec
+		compressor Compressor
+		threshold  int // 编码后数据大小达到阈值时才压缩
+	}
+
+	// legacyDecoder 自定义不带编码头部的旧数据的解码方式，未实现时按JSON解码
+	legacyDecoder interface {
+		decodeLegacy(data []byte) error
+	}
+
+	jsonCodec      struct{}
+	gobCodec       struct{}
+	msgpackCodec   struct{}
+	gzipCompressor struct{}
+	zstdCompressor struct{}
+)
+
+// newCodec 创建编解码器，codec为nil时使用JSON编码，compressor为nil时不压缩
+func newCodec(c Codec, compressor Compressor, threshold int) *codec {
+	if c == nil {
+		c = CodecJSON
+	}
+
+	return &codec{
+		codec:      c,
+		compressor: compressor,
+		threshold:  threshold,
+	}
+}
+
+// encode 编码数据，数据大小达到阈值时压缩，并在头部写入编码ID和压缩ID
+func (c *codec) encode(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal, %w", err)
+	}
+
+	compressorID := CompressorIDNone
+	if c.compressor != nil && len(data) >= c.threshold {
+		data, err = c.compressor.Compress(data)
+		if err != nil {
+			return nil, fmt.Errorf("compress, %w", err)
+		}
+		compressorID = c.compressor.ID()
+	}
+
+	buf := make([]byte, 0, len(data)+1)
+	buf = append(buf, compressorID<<4|c.codec.ID()&0x0f)
+	buf = append(buf, data...)
+
+	return buf, nil
+}
+
+// decode 根据头部的编码ID和压缩ID解码数据。
+// 头部无法识别、或按头部解码失败时按不带头部的JSON数据解码，兼容引入编码头部之前写入的数据；
+// 如JSON字符串的首字节 '"'（0x22）恰好是gob编码、zstd压缩的头部，需在解码失败后回退
+func (c *codec) decode(data []byte, v any) (err error) {
+	if len(data) == 0 {
+		return fmt.Errorf("decode: empty data")
+	}
+
+	header := data[0]
+	dataCodec, compressor, ok := c.lookup(header&0x0f, header>>4)
+	if !ok {
+		return decodeLegacy(data, v)
+	}
+
+	if err = decodeWith(dataCodec, compressor, data[1:], v); err != nil && decodeLegacy(data, v) == nil {
+		return nil
+	}
+
+	return
+}
+
+// decodeWith 使用指定的编码和压缩算法解码不含头部的数据
+func decodeWith(dataCodec Codec, compressor Compressor, data []byte, v any) (err error) {
+	if compressor != nil {
+		data, err = compressor.Decompress(data)
+		if err != nil {
+			return fmt.Errorf("decompress, %w", err)
+		}
+	}
+
+	if err = dataCodec.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshal, %w", err)
+	}
+
+	return
+}
+
+// decodeLegacy 解码引入编码头部之前写入的不带头部的JSON数据
+func decodeLegacy(data []byte, v any) error {
+	if d, ok := v.(legacyDecoder); ok {
+		return d.decodeLegacy(data)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// lookup 根据ID查找编码和压缩算法，优先使用当前配置，其次为内置实现
+func (c *codec) lookup(codecID, compressorID byte) (dataCodec Codec, compressor Compressor, ok bool) {
+	switch {
+	case c.codec.ID() == codecID:
+		dataCodec = c.codec
+	default:
+		dataCodec = builtinCodecs[codecID]
+	}
+
+	if dataCodec == nil {
+		return nil, nil, false
+	}
+
+	if compressorID == CompressorIDNone {
+		return dataCodec, nil, true
+	}
+
+	switch {
+	case c.compressor != nil && c.compressor.ID() == compressorID:
+		compressor = c.compressor
+	default:
+		compressor = builtinCompressors[compressorID]
+	}
+
+	return dataCodec, compressor, compressor != nil
+}
+
+func (jsonCodec) ID() byte {
+	return CodecIDJSON
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (gobCodec) ID() byte {
+	return CodecIDGob
+}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (msgpackCodec) ID() byte {
+	return CodecIDMsgpack
+}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+func (gzipCompressor) ID() byte {
+	return CompressorIDGzip
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return io.ReadAll(r)
+}
+
+func (zstdCompressor) ID() byte {
+	return CompressorIDZstd
+}
+
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	encoder, err := zstdEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return encoder.EncodeAll(data, nil), nil
+}
+
+func (zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	decoder, err := zstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	return decoder.DecodeAll(data, nil)
+}
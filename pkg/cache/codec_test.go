@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	user := benchUser{ID: 1, Name: strings.Repeat("song", 64)}
+
+	for _, dataCodec := range []Codec{CodecJSON, CodecGob, CodecMsgpack} {
+		for _, compressor := range []Compressor{nil, CompressorGzip, CompressorZstd} {
+			c := newCodec(dataCodec, compressor, 0)
+			data, err := c.encode(newPayload(user, 0))
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			// 头部高4位为压缩ID，低4位为编码ID
+			wantHeader := dataCodec.ID()
+			if compressor != nil {
+				wantHeader |= compressor.ID() << 4
+			}
+			if data[0] != wantHeader {
+				t.Fatalf("header = %#x, want %#x", data[0], wantHeader)
+			}
+
+			// 按头部选择编码和压缩算法，与当前配置无关
+			var got payload[benchUser]
+			if err = newCodec(nil, nil, 0).decode(data, &got); err != nil || got.Data != user {
+				t.Fatalf("decode %#x: %+v, %v", data[0], got.Data, err)
+			}
+		}
+	}
+}
+
+func TestCodecCompressionThreshold(t *testing.T) {
+	c := newCodec(CodecJSON, CompressorGzip, 64)
+
+	small, _ := c.encode("song")
+	large, _ := c.encode(strings.Repeat("song", 64))
+	if small[0]>>4 != CompressorIDNone || large[0]>>4 != CompressorIDGzip {
+		t.Fatalf("headers = %#x, %#x", small[0], large[0])
+	}
+}
+
+func TestCodecLegacy(t *testing.T) {
+	c := newCodec(CodecMsgpack, CompressorZstd, 0)
+
+	// 不带头部的JSON字符串首字节 '"' 恰好是gob编码、zstd压缩的头部，解码失败后按JSON解码
+	raw, _ := json.Marshal("song")
+	var s string
+	if err := c.decode(raw, &s); err != nil || s != "song" {
+		t.Fatalf("decode string: %q, %v", s, err)
+	}
+
+	raw, _ = json.Marshal(benchUser{ID: 1, Name: "song"})
+	var p payload[benchUser]
+	if err := c.decode(raw, &p); err != nil || p.Data.ID != 1 || p.SoftAt != 0 {
+		t.Fatalf("decode object: %+v, %v", p, err)
+	}
+
+	if err := c.decode([]byte{0x22, 0xff}, &s); err == nil {
+		t.Fatal("decode invalid data: want error")
+	}
+}
+
+type invalidCodec struct {
+	jsonCodec
+}
+
+func (invalidCodec) ID() byte {
+	return 16
+}
+
+func TestValueCodecInvalidID(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("ValueCodec with id 16: want panic")
+		}
+	}()
+
+	ValueCodec[benchUser](invalidCodec{})(&Cache[benchUser]{})
+}
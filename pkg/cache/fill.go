@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/lock"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"go.uber.org/zap"
+)
+
+const (
+	fillWaitDefault = 2 * time.Second        // 等待其他实例回源的默认时长
+	fillLockTTL     = 3 * time.Second        // 回源锁的默认过期时间
+	fillPollMin     = 20 * time.Millisecond  // 轮询Redis的最小间隔
+	fillPollMax     = 200 * time.Millisecond // 轮询Redis的最大间隔
+)
+
+type (
+	// fillConf 跨实例回源去重配置
+	fillConf struct {
+		enable  bool          // 是否开启，需同时配置RedisCache
+		wait    time.Duration // 未获取到回源锁时等待其他实例回源的时长，超时后自行回源
+		options []lock.Option // 回源锁配置
+		locker  *lock.Lock    // 回源锁
+	}
+)
+
+// startFill 开启跨实例回源去重时，使用Redis缓存的客户端创建回源锁
+func (c *Cache[T]) startFill() {
+	if !c.fill.enable || !c.redisCache.isSet() {
+		c.fill.enable = false
+		return
+	}
+
+	if c.fill.wait <= 0 {
+		c.fill.wait = fillWaitDefault
+	}
+
+	opts := append([]lock.Option{lock.RedisClient(c.redisCache.client), lock.TTL(fillLockTTL)}, c.fill.options...)
+	locker, err := lock.New(opts...)
+	if err != nil {
+		erlogs.Convert(err).Wrap("cache distributed fill disabled").WarnLog(context.Background(),
+			erlogs.OptionFields(zap.String("key_prefix", c.keyPrefix)),
+		)
+		c.fill.enable = false
+		return
+	}
+
+	c.fill.locker = locker
+}
+
+// distributedFill 多级缓存均未命中时，通过分布式锁保证同一时间只有一个实例回源：
+//   - 1）获取到回源锁的实例再次查询Redis，仍未命中时回源并写入各级缓存；
+//   - 2）未获取到回源锁的实例按退避间隔轮询Redis，数据出现后回写LRU并返回；
+//   - 3）轮询未命中时重新竞争回源锁，持锁实例释放锁但未写入数据（如回源数据为零值且未开启负缓存）时，由获取到锁的实例回源；
+//   - 4）等待超过wait或获取锁出错时，自行回源，避免持锁实例异常导致请求失败。
+func (c *Cache[T]) distributedFill(ctx context.Context, name, key any, set SetFunc[T], tags []string) result.Interface[T] {
+	layers := []cacheInterface[T]{c.lruCache, c.redisCache}
+	lockKey := c.redisCache.genKey(c.keyPrefix, key, name, "fill")
+
+	core, ok, err := c.fill.locker.Lock(ctx, lockKey)
+	if ok {
+		return c.fillLocked(ctx, core, name, key, set, tags)
+	}
+
+	if !errors.Is(err, lock.ErrorLockAcquiredByOther) {
+		return c.set(ctx, layers, name, key, c.loader(set), tags...)
+	}
+
+	timer := time.NewTimer(c.fill.wait)
+	defer timer.Stop()
+
+	for interval := fillPollMin; ; interval = min(interval*2, fillPollMax) {
+		select {
+		case <-ctx.Done():
+			return result.Error[T](context.Cause(ctx))
+		case <-timer.C:
+			return c.set(ctx, layers, name, key, c.loader(set), tags...)
+		case <-time.After(interval):
+		}
+
+		if res, hit := c.pollRedis(ctx, name, key, tags); hit {
+			return res
+		}
+
+		// 回源锁已释放而数据仍未写入时，重新竞争回源锁
+		core, ok, err = c.fill.locker.Lock(ctx, lockKey)
+		if ok {
+			return c.fillLocked(ctx, core, name, key, set, tags)
+		}
+
+		if !errors.Is(err, lock.ErrorLockAcquiredByOther) {
+			return c.set(ctx, layers, name, key, c.loader(set), tags...)
+		}
+	}
+}
+
+// fillLocked 持有回源锁时再次查询Redis，仍未命中时回源并写入各级缓存，完成后释放回源锁
+func (c *Cache[T]) fillLocked(ctx context.Context, core *lock.Core, name, key any, set SetFunc[T], tags []string) result.Interface[T] {
+	defer func() {
+		_ = core.Unlock(context.WithoutCancel(ctx))
+	}()
+
+	// 等待锁期间其他实例可能已完成回源
+	if res, hit := c.pollRedis(ctx, name, key, tags); hit {
+		return res
+	}
+
+	return c.set(ctx, []cacheInterface[T]{c.lruCache, c.redisCache}, name, key, c.loader(set), tags...)
+}
+
+// pollRedis 查询Redis缓存，命中（包括负缓存）时回写LRU
+func (c *Cache[T]) pollRedis(ctx context.Context, name, key any, tags []string) (res result.Interface[T], hit bool) {
+	res = c.redisCache.get(ctx, key, c.keyPrefix)
+	if res.Err() != nil || (!IsNegative(res) && c.isZero(res.Data())) {
+		return res, false
+	}
+
+	if c.lruCache.isSet() {
+		_ = c.set(ctx, []cacheInterface[T]{c.lruCache}, name, key,
+			func(ctx context.Context) result.Interface[T] {
+				return res
+			},
+			tags...,
+		)
+	}
+
+	return res, true
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mel0dys0ng/song/pkg/lock"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestFillCache(t *testing.T, client redis.UniversalClient, wait time.Duration) *Cache[benchUser] {
+	t.Helper()
+
+	c := New(
+		RedisCache[benchUser](client, time.Minute),
+		LRUCache[benchUser](100, time.Minute),
+		KeyPrefix[benchUser]("test:"),
+		IsZero(func(data benchUser) bool { return data.ID == 0 }),
+		DataId(func(data benchUser) any { return data.ID }),
+		DistributedFill[benchUser](true, wait),
+	)
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+func TestDistributedFill(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	user := benchUser{ID: 1, Name: "song"}
+	var calls atomic.Int32
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		calls.Add(1)
+		return result.Success(user)
+	}
+
+	// 模拟其他实例持有回源锁并在稍后写入Redis
+	holder := newTestFillCache(t, client, time.Second)
+	locker, err := lock.New(lock.RedisClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, ok, err := locker.Lock(ctx, holder.redisCache.genKey(holder.keyPrefix, "user:1", "user", "fill"))
+	if !ok || err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = holder.redisCache.set(ctx, "user:1", "user", holder.keyPrefix, user.ID, user)
+		_ = core.Unlock(ctx)
+	}()
+
+	waiter := newTestFillCache(t, client, time.Second)
+	if res := waiter.GetOrSet(ctx, "user", "user:1", loader); res.Err() != nil || res.Data() != user {
+		t.Fatalf("GetOrSet: %+v, %v", res.Data(), res.Err())
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("loader called %d times, want 0", calls.Load())
+	}
+	if res := waiter.lruCache.get(ctx, "user:1", waiter.keyPrefix); res.Data() != user {
+		t.Fatalf("lru not backfilled: %+v", res.Data())
+	}
+
+	// 持锁实例未写入数据，等待超时后自行回源
+	core, ok, err = locker.Lock(ctx, holder.redisCache.genKey(holder.keyPrefix, "user:2", "user", "fill"))
+	if !ok || err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer func() {
+		_ = core.Unlock(ctx)
+	}()
+
+	waiter = newTestFillCache(t, client, 100*time.Millisecond)
+	if res := waiter.GetOrSet(ctx, "user", "user:2", loader); res.Err() != nil || res.Data() != user {
+		t.Fatalf("GetOrSet after wait: %+v, %v", res.Data(), res.Err())
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+
+	// 持锁实例释放锁但未写入数据时，等待方重新获取回源锁并回源，无需等待超时
+	core, ok, err = locker.Lock(ctx, holder.redisCache.genKey(holder.keyPrefix, "user:3", "user", "fill"))
+	if !ok || err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = core.Unlock(ctx)
+	}()
+
+	waiter = newTestFillCache(t, client, 2*time.Second)
+	start := time.Now()
+	if res := waiter.GetOrSet(ctx, "user", "user:3", loader); res.Err() != nil || res.Data() != user {
+		t.Fatalf("GetOrSet after unlock: %+v, %v", res.Data(), res.Err())
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second || calls.Load() != 2 {
+		t.Fatalf("loader called %d times after %v, want 2 before the wait budget", calls.Load(), elapsed)
+	}
+}
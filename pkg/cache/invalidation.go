@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"go.uber.org/zap"
+)
+
+const (
+	invalidationChannelSuffix = "invalidation"         // 失效通知频道后缀，频道名为 KeyPrefix + 后缀
+	invalidationMinBackoff    = 100 * time.Millisecond // 订阅断开后的最小重连间隔
+	invalidationMaxBackoff    = 5 * time.Second        // 订阅断开后的最大重连间隔
+)
+
+type (
+	// invalidationConf 跨实例LRU缓存失效配置
+	invalidationConf struct {
+		enable  bool               // 是否开启，默认关闭，同时配置LRU和Redis缓存时生效
+		running bool               // 是否已开启订阅
+		source  string             // 当前实例标识，忽略自身发布的失效通知
+		channel string             // Redis pub/sub 频道
+		cancel  context.CancelFunc // 停止订阅
+		stopped chan struct{}      // 订阅协程已退出
+	}
+
+	// invalidationMessage 失效通知消息
+	invalidationMessage struct {
+		Source string   `json:"source"` // 发布实例标识
+		Keys   []string `json:"keys"`   // 需要失效的LRU缓存键（genKey生成）
+		Tags   []string `json:"tags"`   // 需要失效的LRU标签集合键（genTagKey生成）
+	}
+)
+
+// startInvalidation 开启失效通知且同时开启LRU和Redis缓存时，订阅失效通知频道，收到通知后淘汰本地LRU缓存
+func (c *Cache[T]) startInvalidation() {
+	if !c.invalidation.enable || !c.lruCache.isSet() || !c.redisCache.isSet() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.invalidation = invalidationConf{
+		enable:  true,
+		running: true,
+		source:  uuid.New().String(),
+		channel: c.keyPrefix + invalidationChannelSuffix,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+
+	go c.subscribe(ctx)
+}
+
+// subscribe 订阅失效通知，连接断开后按指数退避重连。
+// 断连期间可能丢失通知，因此重连成功后清空本地LRU缓存
+func (c *Cache[T]) subscribe(ctx context.Context) {
+	defer close(c.invalidation.stopped)
+
+	backoff, reconnect := invalidationMinBackoff, false
+	for ctx.Err() == nil {
+		err := c.receive(ctx, &reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+
+		erlogs.Convert(err).Wrap("cache invalidation subscription broken").WarnLog(ctx,
+			erlogs.OptionFields(zap.String("channel", c.invalidation.channel), zap.Duration("backoff", backoff)),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, invalidationMaxBackoff)
+		reconnect = true
+	}
+}
+
+// receive 建立订阅并持续处理失效通知，直到出错或ctx取消
+func (c *Cache[T]) receive(ctx context.Context, reconnect *bool) error {
+	pubsub := c.redisCache.client.Subscribe(ctx, c.invalidation.channel)
+	// 阻塞读取时不会响应ctx取消，ctx取消时关闭订阅使读取立即返回
+	stop := context.AfterFunc(ctx, func() {
+		_ = pubsub.Close()
+	})
+	defer func() {
+		stop()
+		_ = pubsub.Close()
+	}()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	if *reconnect {
+		c.lruCache.purge()
+		*reconnect = false
+	}
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("receive: %w", err)
+		}
+
+		var message invalidationMessage
+		if err = json.Unmarshal([]byte(msg.Payload), &message); err != nil || message.Source == c.invalidation.source {
+			continue
+		}
+
+		for _, keyKey := range message.Keys {
+			c.lruCache.delByKeyKey(keyKey)
+		}
+
+		for _, tagKey := range message.Tags {
+			c.lruCache.invalidateTagKey(tagKey)
+		}
+	}
+}
+
+// publish 发布失效通知，通知其他实例淘汰keys对应的LRU缓存
+func (c *Cache[T]) publish(ctx context.Context, keys ...any) {
+	if !c.invalidation.running || len(keys) == 0 {
+		return
+	}
+
+	message := invalidationMessage{Source: c.invalidation.source, Keys: make([]string, 0, len(keys))}
+	for _, key := range keys {
+		message.Keys = append(message.Keys, c.lruCache.genKey(c.keyPrefix, key))
+	}
+
+	c.send(ctx, message)
+}
+
+// publishTags 发布失效通知，通知其他实例淘汰打上tags的LRU缓存
+func (c *Cache[T]) publishTags(ctx context.Context, tags ...string) {
+	if !c.invalidation.running || len(tags) == 0 {
+		return
+	}
+
+	message := invalidationMessage{Source: c.invalidation.source, Tags: make([]string, 0, len(tags))}
+	for _, tag := range tags {
+		message.Tags = append(message.Tags, c.lruCache.genTagKey(c.keyPrefix, tag))
+	}
+
+	c.send(ctx, message)
+}
+
+// send 将失效通知发布到频道，发布失败时记录告警日志
+func (c *Cache[T]) send(ctx context.Context, message invalidationMessage) {
+	bytes, err := json.Marshal(message)
+	if err == nil {
+		err = c.redisCache.client.Publish(ctx, c.invalidation.channel, bytes).Err()
+	}
+
+	if err != nil {
+		erlogs.Convert(err).Wrap("cache invalidation publish failed").WarnLog(ctx,
+			erlogs.OptionFields(
+				zap.String("channel", c.invalidation.channel),
+				zap.Strings("keys", message.Keys),
+				zap.Strings("tags", message.Tags),
+			),
+		)
+	}
+}
+
+// Close 停止跨实例缓存失效通知的订阅，WriteBehind模式下停止接收新数据并等待队列中的数据全部持久化（最多等待WriteBehindTimeout的close），
+// 未开启时为空操作
+func (c *Cache[T]) Close() {
+	if c.write.queue != nil {
+		c.write.queue.close(c.write.closeTimeout)
+	}
+
+	if !c.invalidation.running || c.invalidation.cancel == nil {
+		return
+	}
+
+	c.invalidation.cancel()
+	<-c.invalidation.stopped
+}
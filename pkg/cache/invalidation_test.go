@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	newClient := func() redis.UniversalClient {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		return client
+	}
+
+	// 两个实例共享Redis，各自持有本地LRU缓存
+	a := newTestCache(t, newClient(), Invalidation[benchUser](true))
+	b := newTestCache(t, newClient(), Invalidation[benchUser](true))
+	waitFor(t, func() bool {
+		return mr.PubSubNumSub(a.invalidation.channel)[a.invalidation.channel] == 2
+	})
+
+	user := benchUser{ID: 1, Name: "a"}
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		return result.Success(user)
+	}
+
+	_ = a.GetOrSet(ctx, "user", "user:1", loader)
+	_ = b.GetOrSet(ctx, "user", "user:1", loader)
+	if res := b.lruCache.get(ctx, "user:1", b.keyPrefix); res.Data() != user {
+		t.Fatalf("lru get: %+v", res.Data())
+	}
+
+	// 其他实例删除后淘汰本地LRU缓存
+	_ = a.Del(ctx, "user:1")
+	waitFor(t, func() bool {
+		return b.lruCache.get(ctx, "user:1", b.keyPrefix).Data() == benchUser{}
+	})
+
+	// 其他实例批量回源覆盖写后淘汰本地LRU缓存，包括ID映射
+	_ = b.lruCache.set(ctx, "user:1", "user", b.keyPrefix, user.ID, user)
+	updated := benchUser{ID: 1, Name: "b"}
+	_ = a.GetOrSetMany(ctx, "user", []any{"user:1"}, func(ctx context.Context, keys []any) result.Interface[map[any]benchUser] {
+		return result.Success(map[any]benchUser{"user:1": updated})
+	})
+	waitFor(t, func() bool {
+		return b.lruCache.get(ctx, user.ID, b.keyPrefix).Data() == benchUser{}
+	})
+
+	if res := b.GetOrSet(ctx, "user", "user:1", loader); res.Data() != updated {
+		t.Fatalf("GetOrSet after overwrite: %+v", res.Data())
+	}
+
+	// Close停止订阅后返回
+	done := make(chan struct{})
+	go func() {
+		a.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+}
+
+func TestInvalidationDefaultOff(t *testing.T) {
+	c := newTestCache(t, nil)
+	if c.invalidation.running {
+		t.Fatal("invalidation subscribed without Invalidation(true)")
+	}
+
+	// 未开启时Close为空操作
+	c.Close()
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
@@ -11,24 +14,60 @@ import (
 	"github.com/mel0dys0ng/song/pkg/result"
 )
 
+const (
+	EvictReasonCapacity EvictReason = "capacity" // 数量或内存超出上限被淘汰
+	EvictReasonExpired  EvictReason = "expired"  // 超过硬过期时间被淘汰
+
+	lruKeysPerValue = 4 // 每条数据在keyClient中占用的键数量：keyKey、idKey、idNameKey及额外的别名键
+)
+
 type (
+	// EvictReason LRU缓存数据的淘汰原因
+	EvictReason string
+
+	// CostFunc 计算数据占用的内存字节数
+	CostFunc[T any] func(value T) int64
+
+	// EvictFunc LRU缓存数据被淘汰或过期时的回调，在LRU缓存的锁内同步执行，不应阻塞或调用Cache的方法
+	EvictFunc[T any] func(value T, cost int64, reason EvictReason)
+
 	// lruCache LRU缓存结构体
 	lruCache[T any] struct {
 		keyClient   *expirable.LRU[string, []string]
-		valueClient *expirable.LRU[string, T]
-		size        int // 缓存数量
-		ttl         time.Duration
+		valueClient *expirable.LRU[string, *lruEntry[T]]
+		tagClient   *expirable.LRU[string, []string] // 标签集合键 -> 数据键列表
+		size        int                              // 缓存数量
+		ttl         time.Duration                    // 硬过期时间，过期后淘汰
+		softTTL     time.Duration                    // 软过期时间，过期后仍返回数据并触发后台刷新
+		stats       *stats                           // 统计计数器
+		maxCost     int64                            // 内存上限（字节），0表示不限制
+		costFunc    CostFunc[T]                      // 计算数据占用的内存，为nil时使用编码后的大小
+		codec       *codec                           // 未配置costFunc时用于计算编码后的大小
+		onEvict     EvictFunc[T]                     // 数据被淘汰或过期时的回调
+		cost        atomic.Int64                     // 当前占用的内存
+		purging     atomic.Bool                      // 正在清空缓存，不触发淘汰回调
+		mu          sync.Mutex                       // 保证写入数据与内存统计一致
+	}
+
+	// lruEntry LRU缓存数据及其占用的内存
+	lruEntry[T any] struct {
+		payload  payload[T]
+		cost     int64
+		expireAt int64       // 硬过期时间（纳秒时间戳），用于区分过期和容量淘汰
+		removed  atomic.Bool // 主动删除，不触发淘汰回调
 	}
 )
 
-// newLRUCache 创建一个新的LRU缓存实例
+// newLRUCache 创建一个新的LRU缓存实例，最多缓存size条数据
 func newLRUCache[T any](size int, ttl time.Duration) *lruCache[T] {
-	return &lruCache[T]{
-		keyClient:   expirable.NewLRU[string, []string](size, nil, ttl),
-		valueClient: expirable.NewLRU[string, T](size*10, nil, ttl),
-		size:        size,
-		ttl:         ttl,
+	c := &lruCache[T]{
+		keyClient: expirable.NewLRU[string, []string](size*lruKeysPerValue, nil, ttl),
+		tagClient: expirable.NewLRU[string, []string](size, nil, ttl),
+		size:      size,
+		ttl:       ttl,
 	}
+	c.valueClient = expirable.NewLRU[string, *lruEntry[T]](size, c.evicted, ttl)
+	return c
 }
 
 // isSet 检查LRU缓存是否已设置
@@ -48,29 +87,39 @@ func (c *lruCache[T]) getType() string {
 
 // get 从LRU缓存中获取数据
 func (c *lruCache[T]) get(ctx context.Context, key any, prefix string) (res result.Interface[T]) {
-	var data T
+	var data payload[T]
 
 	keyKey := c.genKey(prefix, key)
 	idNameKeys, _ := c.keyClient.Get(keyKey)
+	if len(idNameKeys) > 0 && isTombstone(idNameKeys[0]) {
+		if c.isTombstoneAlive(idNameKeys) {
+			return negativeResult[T]()
+		}
+		_ = c.keyClient.Remove(keyKey)
+		return result.Success(data.Data)
+	}
+
 	if len(idNameKeys) > 0 {
-		data, _ = c.valueClient.Get(idNameKeys[0])
+		if entry, ok := c.valueClient.Get(idNameKeys[0]); ok {
+			data = entry.payload
+		}
 	}
 
-	return result.Success(data)
+	return data.result()
 }
 
-// set 将数据设置到LRU缓存中
-func (c *lruCache[T]) set(ctx context.Context, key, name any, prefix string, id any, value T) (res result.Interface[T]) {
+// set 将数据设置到LRU缓存中，并将数据键加入每个标签集合
+func (c *lruCache[T]) set(ctx context.Context, key, name any, prefix string, id any, value T, tags ...string) (res result.Interface[T]) {
 	keyKey := c.genKey(prefix, key)
 	idNameKeys, _ := c.keyClient.Get(keyKey)
-	if len(idNameKeys) == 0 {
+	if len(idNameKeys) == 0 || isTombstone(idNameKeys[0]) {
 		idNameKeys = []string{c.genKey(prefix, name, id)}
-		c.keyClient.Add(keyKey, idNameKeys)
+		c.addKey(keyKey, idNameKeys)
 	}
 
 	idKey := c.genKey(prefix, id)
 	if v, _ := c.keyClient.Get(idKey); len(v) == 0 {
-		c.keyClient.Add(idKey, idNameKeys)
+		c.addKey(idKey, idNameKeys)
 	}
 
 	idNameKey := idNameKeys[0]
@@ -84,12 +133,119 @@ func (c *lruCache[T]) set(ctx context.Context, key, name any, prefix string, id
 		}
 	}
 
-	c.keyClient.Add(idNameKey, keys)
-	c.valueClient.Add(idNameKey, value)
+	c.addKey(idNameKey, keys)
+	c.addValue(idNameKey, value)
+	c.addTags(prefix, idNameKey, tags)
 
 	return result.Success(value)
 }
 
+// addKey 写入映射关系，keyClient容量不足淘汰时记录映射关系淘汰次数
+func (c *lruCache[T]) addKey(key string, value []string) {
+	if c.keyClient.Add(key, value) && c.stats != nil {
+		c.stats.observeEviction(TypeLRU, EventKeyEviction)
+	}
+}
+
+// addValue 写入数据并统计占用的内存，超出内存上限时按LRU顺序淘汰数据，单条数据超出上限时不缓存
+func (c *lruCache[T]) addValue(idNameKey string, value T) {
+	entry := &lruEntry[T]{payload: newPayload(value, c.softTTL)}
+	if c.ttl > 0 {
+		entry.expireAt = time.Now().Add(c.ttl).UnixNano()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxCost > 0 {
+		entry.cost = c.costOf(entry.payload)
+		if entry.cost > c.maxCost {
+			c.removeLocked(idNameKey)
+			return
+		}
+	}
+
+	// 覆盖已有数据时不会触发淘汰回调，需扣除原数据占用的内存
+	if old, ok := c.valueClient.Peek(idNameKey); ok {
+		c.cost.Add(-old.cost)
+	}
+
+	c.cost.Add(entry.cost)
+	c.valueClient.Add(idNameKey, entry)
+
+	for c.maxCost > 0 && c.cost.Load() > c.maxCost {
+		if _, _, ok := c.valueClient.RemoveOldest(); !ok {
+			break
+		}
+	}
+}
+
+// costOf 计算数据占用的内存，未配置costFunc时使用编码后的大小
+func (c *lruCache[T]) costOf(data payload[T]) int64 {
+	if c.costFunc != nil {
+		return c.costFunc(data.Data)
+	}
+
+	if c.codec == nil {
+		c.codec = newCodec(nil, nil, 0)
+	}
+
+	bytes, err := c.codec.encode(data)
+	if err != nil {
+		return 0
+	}
+
+	return int64(len(bytes))
+}
+
+// evicted 数据被淘汰、过期或删除时扣除占用的内存。
+// 被淘汰或过期时同时删除keyClient中仍指向该数据的别名键和关联键列表，并触发淘汰回调
+func (c *lruCache[T]) evicted(idNameKey string, entry *lruEntry[T]) {
+	c.cost.Add(-entry.cost)
+	if entry.removed.Load() || c.purging.Load() {
+		return
+	}
+
+	keys, _ := c.keyClient.Peek(idNameKey)
+	for _, key := range keys {
+		if v, _ := c.keyClient.Peek(key); len(v) > 0 && v[0] == idNameKey {
+			_ = c.keyClient.Remove(key)
+		}
+	}
+	_ = c.keyClient.Remove(idNameKey)
+
+	reason := EvictReasonCapacity
+	if entry.expireAt > 0 && time.Now().UnixNano() >= entry.expireAt {
+		reason = EvictReasonExpired
+	}
+
+	if reason == EvictReasonCapacity && c.stats != nil {
+		c.stats.observeEviction(TypeLRU, EventEviction)
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(entry.payload.Data, entry.cost, reason)
+	}
+}
+
+// remove 主动删除数据，不触发淘汰回调，返回数据是否存在
+func (c *lruCache[T]) remove(idNameKey string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.removeLocked(idNameKey)
+}
+
+// removeLocked 在持有c.mu时主动删除数据。
+// 写入数据同样持有c.mu，保证标记的数据即为被删除的数据，不会误删并发写入的新数据而触发淘汰回调
+func (c *lruCache[T]) removeLocked(idNameKey string) bool {
+	if entry, ok := c.valueClient.Peek(idNameKey); ok {
+		entry.removed.Store(true)
+	}
+
+	return c.valueClient.Remove(idNameKey)
+}
+
 // del 从LRU缓存中删除数据
 func (c *lruCache[T]) del(ctx context.Context, key any, prefix string) (res result.Interface[T]) {
 	getRes := c.get(ctx, key, prefix)
@@ -97,29 +253,113 @@ func (c *lruCache[T]) del(ctx context.Context, key any, prefix string) (res resu
 		return getRes
 	}
 
-	keyKey := c.genKey(prefix, key)
+	c.delByKeyKey(c.genKey(prefix, key))
+
+	return result.Success(getRes.Data())
+}
+
+// delByKeyKey 根据genKey生成的键删除LRU缓存数据，以及与该数据关联的所有键和墓碑标记
+func (c *lruCache[T]) delByKeyKey(keyKey string) {
 	defer func() {
 		_ = c.keyClient.Remove(keyKey)
 	}()
 
 	idNameKeys, _ := c.keyClient.Get(keyKey)
-	if len(idNameKeys) == 0 {
-		var data T
-		return result.Success(data)
+	if len(idNameKeys) == 0 || isTombstone(idNameKeys[0]) {
+		return
 	}
 
-	idNameKey := idNameKeys[0]
-	_ = c.valueClient.Remove(idNameKey)
+	c.delByIdNameKey(idNameKeys[0])
+}
 
+// delByIdNameKey 根据数据键删除LRU缓存数据以及与该数据关联的所有键，返回数据是否存在
+func (c *lruCache[T]) delByIdNameKey(idNameKey string) bool {
 	keys, _ := c.keyClient.Get(idNameKey)
 	for _, v := range keys {
 		_ = c.keyClient.Remove(v)
 	}
+	_ = c.keyClient.Remove(idNameKey)
 
-	return result.Success(getRes.Data())
+	return c.remove(idNameKey)
+}
+
+// purge 清空LRU缓存
+func (c *lruCache[T]) purge() {
+	c.purging.Store(true)
+	defer c.purging.Store(false)
+
+	c.keyClient.Purge()
+	c.valueClient.Purge()
+	c.tagClient.Purge()
+}
+
+// setTombstone 在LRU缓存中写入墓碑标记，墓碑的过期时间记录在标记之后
+func (c *lruCache[T]) setTombstone(ctx context.Context, key any, prefix string, ttl time.Duration) (res result.Interface[T]) {
+	expireAt := time.Now().Add(ttl).UnixNano()
+	c.addKey(c.genKey(prefix, key), []string{tombstone, strconv.FormatInt(expireAt, 10)})
+
+	var data T
+	return result.Success(data)
+}
+
+// setTombstones 在LRU缓存中批量写入墓碑标记
+func (c *lruCache[T]) setTombstones(ctx context.Context, keys []any, prefix string, ttl time.Duration) (res result.Interface[int]) {
+	for _, key := range keys {
+		_ = c.setTombstone(ctx, key, prefix, ttl)
+	}
+
+	return result.Success(len(keys))
+}
+
+// isTombstoneAlive 判断墓碑标记是否仍在有效期内
+func (c *lruCache[T]) isTombstoneAlive(idNameKeys []string) bool {
+	if len(idNameKeys) < 2 {
+		return false
+	}
+
+	expireAt, err := strconv.ParseInt(idNameKeys[1], 10, 64)
+	return err == nil && time.Now().UnixNano() < expireAt
 }
 
 // genKey 生成缓存键
 func (c *lruCache[T]) genKey(prefix string, data ...any) string {
 	return fmt.Sprintf("%s%s", prefix, crypto.MD5(data))
-}
\ No newline at end of file
+}
+
+// getMany 从LRU缓存中批量获取数据，仅返回命中的键，超过软过期时间的数据标记为stale，命中墓碑标记的键返回负缓存结果
+func (c *lruCache[T]) getMany(ctx context.Context, keys []any, prefix string) (res result.Interface[map[any]result.Interface[T]]) {
+	data := make(map[any]result.Interface[T], len(keys))
+	for _, key := range keys {
+		keyKey := c.genKey(prefix, key)
+		idNameKeys, _ := c.keyClient.Get(keyKey)
+		if len(idNameKeys) == 0 {
+			continue
+		}
+
+		if isTombstone(idNameKeys[0]) {
+			if c.isTombstoneAlive(idNameKeys) {
+				data[key] = negativeResult[T]()
+			} else {
+				_ = c.keyClient.Remove(keyKey)
+			}
+			continue
+		}
+
+		if entry, ok := c.valueClient.Get(idNameKeys[0]); ok {
+			data[key] = entry.payload.result()
+		}
+	}
+
+	return result.Success(data)
+}
+
+// setMany 将数据批量设置到LRU缓存中
+func (c *lruCache[T]) setMany(ctx context.Context, name any, prefix string, items []item[T]) (res result.Interface[map[any]T]) {
+	data := make(map[any]T, len(items))
+	for _, v := range items {
+		_ = c.set(ctx, v.key, name, prefix, v.id, v.value, v.tags...)
+		data[v.key] = v.value
+	}
+
+	return result.Success(data)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheMaxCost(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mu      sync.Mutex
+		evicted []EvictReason
+	)
+	reasons := func() []EvictReason {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(evicted)
+	}
+	c := New(
+		LRUCache[benchUser](100, 300*time.Millisecond),
+		KeyPrefix[benchUser]("test:"),
+		IsZero(func(data benchUser) bool { return data.ID == 0 }),
+		DataId(func(data benchUser) any { return data.ID }),
+		LRUMaxCost(100, func(value benchUser) int64 { return int64(len(value.Name)) }),
+		LRUEvict(func(value benchUser, cost int64, reason EvictReason) {
+			mu.Lock()
+			evicted = append(evicted, reason)
+			mu.Unlock()
+		}),
+	)
+	lru := c.lruCache
+
+	name := string(make([]byte, 40))
+	for i := int64(1); i <= 3; i++ {
+		_ = lru.set(ctx, fmt.Sprintf("user:%d", i), "user", c.keyPrefix, i, benchUser{ID: i, Name: name})
+	}
+
+	// 第3条数据写入后超出内存上限，淘汰最早写入的数据及其别名键
+	if lru.cost.Load() != 80 || lru.valueClient.Len() != 2 {
+		t.Fatalf("cost = %d, len = %d, want 80, 2", lru.cost.Load(), lru.valueClient.Len())
+	}
+	if res := lru.get(ctx, "user:1", c.keyPrefix); res.Data() != (benchUser{}) {
+		t.Fatalf("evicted value still cached: %+v", res.Data())
+	}
+	if lru.keyClient.Len() != 2*3 {
+		t.Fatalf("keyClient len = %d, want %d", lru.keyClient.Len(), 2*3)
+	}
+	if !slices.Equal(reasons(), []EvictReason{EvictReasonCapacity}) || c.Stats().LRU.Evictions != 1 {
+		t.Fatalf("evicted = %v, evictions = %d", reasons(), c.Stats().LRU.Evictions)
+	}
+
+	// 覆盖写扣除原数据占用的内存
+	_ = lru.set(ctx, "user:3", "user", c.keyPrefix, int64(3), benchUser{ID: 3, Name: "a"})
+	if lru.cost.Load() != 41 {
+		t.Fatalf("cost after overwrite = %d, want 41", lru.cost.Load())
+	}
+
+	// 单条数据超出上限时不缓存
+	_ = lru.set(ctx, "user:4", "user", c.keyPrefix, int64(4), benchUser{ID: 4, Name: string(make([]byte, 101))})
+	if res := lru.get(ctx, "user:4", c.keyPrefix); res.Data() != (benchUser{}) {
+		t.Fatalf("oversized value cached: %+v", res.Data())
+	}
+
+	// 主动删除不触发回调
+	_ = lru.del(ctx, "user:2", c.keyPrefix)
+	if len(reasons()) != 1 || lru.cost.Load() != 1 {
+		t.Fatalf("evicted = %v, cost = %d after del", reasons(), lru.cost.Load())
+	}
+
+	// 过期淘汰
+	time.Sleep(300 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for lru.valueClient.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !slices.Equal(reasons(), []EvictReason{EvictReasonCapacity, EvictReasonExpired}) || lru.cost.Load() != 0 {
+		t.Fatalf("evicted = %v, cost = %d after expiry", reasons(), lru.cost.Load())
+	}
+}
+
+func TestLRUCacheRemoveConcurrentAdd(t *testing.T) {
+	var evicted atomic.Int32
+	c := newLRUCache[benchUser](100, time.Minute)
+	c.onEvict = func(value benchUser, cost int64, reason EvictReason) {
+		evicted.Add(1)
+	}
+
+	// 主动删除与并发写入同一数据时，不应触发淘汰回调
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				c.addValue("user:1", benchUser{ID: int64(j)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				c.remove("user:1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := evicted.Load(); n != 0 {
+		t.Fatalf("onEvict called %d times for removed entries", n)
+	}
+}
@@ -3,7 +3,9 @@ package cache
 import (
 	"time"
 
+	"github.com/mel0dys0ng/song/pkg/lock"
 	"github.com/mel0dys0ng/song/pkg/retry"
+	"github.com/mel0dys0ng/song/pkg/sys"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,7 +16,7 @@ func RedisCache[T any](client redis.UniversalClient, ttl time.Duration) Option[T
 	}
 }
 
-// LRUCache 配置LRU缓存选项
+// LRUCache 配置LRU缓存选项，最多缓存size条数据，可通过LRUMaxCost限制占用的内存
 func LRUCache[T any](size int, ttl time.Duration) Option[T] {
 	return func(c *Cache[T]) {
 		c.lruCache = newLRUCache[T](size, ttl)
@@ -52,3 +54,124 @@ func KeyPrefix[T any](s string) Option[T] {
 		c.keyPrefix = s
 	}
 }
+
+// SoftTTL 配置软过期时间，需小于LRUCache和RedisCache的ttl（硬过期时间）。
+// 数据超过软过期时间后，GetOrSet直接返回旧数据并在后台刷新；默认为0，不启用
+func SoftTTL[T any](ttl time.Duration) Option[T] {
+	return func(c *Cache[T]) {
+		c.softTTL = ttl
+	}
+}
+
+// NegativeTTL 配置负缓存（墓碑标记）过期时间，默认为0，不启用。
+// 开启后set函数返回零值（由IsZero判断）时，在各级缓存写入墓碑标记，有效期内GetOrSet直接返回零值而不回源，用于防止缓存穿透
+func NegativeTTL[T any](ttl time.Duration) Option[T] {
+	return func(c *Cache[T]) {
+		c.negativeTTL = ttl
+	}
+}
+
+// Invalidation 配置是否开启跨实例LRU缓存失效通知，默认关闭，需同时配置LRUCache和RedisCache。
+// 开启后Del和覆盖写会通过Redis pub/sub（频道为KeyPrefix+"invalidation"）通知其他实例淘汰本地LRU缓存，
+// 每个缓存对象占用一个订阅连接和一个协程，不再使用时需调用Close停止订阅
+func Invalidation[T any](enable bool) Option[T] {
+	return func(c *Cache[T]) {
+		c.invalidation.enable = enable
+	}
+}
+
+// ValueCodec 配置Redis缓存数据（包括关联键列表）的编码，默认为CodecJSON。
+// 数据头部记录了编码ID，更换编码后旧数据仍可按原编码解码，无需清空Redis；编码ID不在1~15范围内时panic
+func ValueCodec[T any](codec Codec) Option[T] {
+	return func(c *Cache[T]) {
+		if codec != nil && (codec.ID() == 0 || codec.ID() > 0x0f) {
+			sys.Panicf("invalid codec id %d, must be in 1~15", codec.ID())
+		}
+		c.codec.codec = codec
+	}
+}
+
+// Compression 配置Redis缓存数据的压缩算法，编码后数据大小达到threshold字节时压缩，默认不压缩。
+// 压缩ID不在1~15范围内时panic
+func Compression[T any](compressor Compressor, threshold int) Option[T] {
+	return func(c *Cache[T]) {
+		if compressor != nil && (compressor.ID() == CompressorIDNone || compressor.ID() > 0x0f) {
+			sys.Panicf("invalid compressor id %d, must be in 1~15", compressor.ID())
+		}
+		c.codec.compressor = compressor
+		c.codec.threshold = threshold
+	}
+}
+
+// Cluster 配置Redis集群模式，默认根据RedisCache的客户端类型自动判断（*redis.ClusterClient为集群模式）。
+// 数据键及其关联键集合共用 {idNameKey} hashtag 位于同一槽位；集群模式下分布在不同槽位的别名键按槽位分别写入和删除，
+// 避免 CROSSSLOT 错误。KeyPrefix 中不应包含 "{" 或 "}"，否则会改变hashtag。
+// 非集群模式使用的写入、删除脚本会访问未通过KEYS传入的键，通过代理等无法自动判断的方式访问集群时需显式开启
+func Cluster[T any](enable bool) Option[T] {
+	return func(c *Cache[T]) {
+		c.cluster = &enable
+	}
+}
+
+// Statistics 配置统计事件钩子，每次缓存读取、回源、淘汰等事件都会同步调用，可用于导出监控指标
+func Statistics[T any](hook StatsHook) Option[T] {
+	return func(c *Cache[T]) {
+		c.statsHook = hook
+	}
+}
+
+// DistributedFill 配置跨实例回源去重，需同时配置RedisCache，默认关闭。
+// 开启后GetOrSet在多级缓存均未命中时先获取以缓存键命名的分布式锁（pkg/lock，默认TTL为3秒），只有持锁实例回源，
+// 其他实例按退避间隔轮询Redis直到数据出现，等待超过wait（默认2秒）后自行回源。opts可覆盖回源锁的TTL和获取超时时间
+func DistributedFill[T any](enable bool, wait time.Duration, opts ...lock.Option) Option[T] {
+	return func(c *Cache[T]) {
+		c.fill = fillConf{
+			enable:  enable,
+			wait:    wait,
+			options: opts,
+		}
+	}
+}
+
+// Write 配置Set的写入模式，默认为WriteThrough
+func Write[T any](mode WriteMode) Option[T] {
+	return func(c *Cache[T]) {
+		c.write.mode = mode
+	}
+}
+
+// WriteBehindQueue 配置WriteBehind模式的异步持久化队列，size为队列容量（不同键的数量，默认1024），
+// onError为重试后仍持久化失败时的回调（为nil时记录错误日志），opts为持久化的重试配置（默认为retry的默认配置）
+func WriteBehindQueue[T any](size int, onError WriteErrorFunc[T], opts ...retry.Option) Option[T] {
+	return func(c *Cache[T]) {
+		c.write.size = size
+		c.write.onError = onError
+		c.write.options = opts
+	}
+}
+
+// WriteBehindTimeout 配置WriteBehind模式的超时时间，flush为单条数据持久化（含重试）的超时时间，默认5秒；
+// close为Close等待队列持久化完成的最长时间，默认30秒，超时后取消剩余数据的持久化并交由错误回调处理
+func WriteBehindTimeout[T any](flush, close time.Duration) Option[T] {
+	return func(c *Cache[T]) {
+		c.write.flushTimeout = flush
+		c.write.closeTimeout = close
+	}
+}
+
+// LRUMaxCost 配置LRU缓存的内存上限（字节），默认不限制。
+// 数据占用的内存由cost计算，cost为nil时使用数据按ValueCodec编码（不压缩）后的大小；
+// 超出上限时按LRU顺序淘汰数据，单条数据超出上限时不写入LRU缓存
+func LRUMaxCost[T any](maxCost int64, cost CostFunc[T]) Option[T] {
+	return func(c *Cache[T]) {
+		c.lruConf.maxCost = maxCost
+		c.lruConf.costFunc = cost
+	}
+}
+
+// LRUEvict 配置LRU缓存数据因数量或内存超出上限被淘汰、或超过ttl过期时的回调，Del等主动删除不触发回调
+func LRUEvict[T any](fn EvictFunc[T]) Option[T] {
+	return func(c *Cache[T]) {
+		c.lruConf.onEvict = fn
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/result"
+)
+
+const (
+	tombstone = "\x00tombstone" // 负缓存墓碑标记，存储在 key->idNameKey 映射中
+)
+
+type (
+	// payload 缓存中实际存储的数据，携带软过期时间
+	payload[T any] struct {
+		Data   T     `json:"d"`           // 缓存数据
+		SoftAt int64 `json:"s,omitempty"` // 软过期时间（UnixNano），0表示未启用软过期
+	}
+
+	// Result 缓存操作结果，在 result.Interface 的基础上携带缓存状态
+	Result[T any] struct {
+		result.Interface[T]
+		stale      bool // 数据已超过软过期时间
+		refreshing bool // 本次调用发起了后台刷新，并发发起的刷新合并为一次回源
+		negative   bool // 命中负缓存（墓碑标记）
+	}
+)
+
+// newPayload 创建缓存数据，softTTL>0 时设置软过期时间
+func newPayload[T any](data T, softTTL time.Duration) payload[T] {
+	p := payload[T]{Data: data}
+	if softTTL > 0 {
+		p.SoftAt = time.Now().Add(softTTL).UnixNano()
+	}
+	return p
+}
+
+// decodeLegacy 解码不带编码头部的JSON数据，兼容引入payload之前直接存储T的数据。
+// 仅包含"d"和"s"字段的对象按payload解码，其余按T解码且不设置软过期时间
+func (p *payload[T]) decodeLegacy(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil {
+		_, hasData := fields["d"]
+		_, hasSoftAt := fields["s"]
+		if hasData && (len(fields) == 1 || hasSoftAt && len(fields) == 2) {
+			return json.Unmarshal(data, p)
+		}
+	}
+
+	p.SoftAt = 0
+	return json.Unmarshal(data, &p.Data)
+}
+
+// isStale 判断数据是否已超过软过期时间
+func (p payload[T]) isStale() bool {
+	return p.SoftAt > 0 && time.Now().UnixNano() > p.SoftAt
+}
+
+// result 将缓存数据转换为结果，超过软过期时间的数据标记为stale
+func (p payload[T]) result() result.Interface[T] {
+	if p.isStale() {
+		return &Result[T]{Interface: result.Success(p.Data), stale: true}
+	}
+	return result.Success(p.Data)
+}
+
+// isTombstone 判断 key->idNameKey 映射的值是否为墓碑标记
+func isTombstone(idNameKey string) bool {
+	return idNameKey == tombstone
+}
+
+// negativeResult 命中负缓存的结果
+func negativeResult[T any]() result.Interface[T] {
+	var zero T
+	return &Result[T]{Interface: result.Success(zero), negative: true}
+}
+
+// Stale 数据是否已超过软过期时间（过期但仍在硬过期时间内的数据）
+func (r *Result[T]) Stale() bool {
+	return r != nil && r.stale
+}
+
+// Refreshing 本次调用是否发起了后台刷新，并发发起的刷新合并为一次回源
+func (r *Result[T]) Refreshing() bool {
+	return r != nil && r.refreshing
+}
+
+// Negative 是否命中负缓存（墓碑标记）
+func (r *Result[T]) Negative() bool {
+	return r != nil && r.negative
+}
+
+// IsStale 判断 GetOrSet 返回的数据是否为超过软过期时间的旧数据
+func IsStale[T any](res result.Interface[T]) bool {
+	r, ok := res.(*Result[T])
+	return ok && r.Stale()
+}
+
+// IsRefreshing 判断 GetOrSet 是否发起了后台刷新
+func IsRefreshing[T any](res result.Interface[T]) bool {
+	r, ok := res.(*Result[T])
+	return ok && r.Refreshing()
+}
+
+// IsNegative 判断 GetOrSet 是否命中负缓存（墓碑标记），命中时返回零值且未回源
+func IsNegative[T any](res result.Interface[T]) bool {
+	r, ok := res.(*Result[T])
+	return ok && r.Negative()
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"slices"
@@ -13,20 +12,83 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	mgetBatchSize = 100     // pipeline中单个MGET命令的最大键数量
+	keysKeySuffix = ":keys" // 关联键集合的后缀，集合键为 idNameKey + 后缀
+)
+
+var (
+	// setScript 原子写入数据及其映射关系
+	//   - KEYS: keyKey, idKey, idNameKey（keyKey无有效映射时使用）, tagKey...
+	//   - ARGV: 编码后的数据, ttl（毫秒）, 关联键集合后缀, 墓碑标记
+	//
+	// keyKey已映射到其他idNameKey时沿用原映射；idKey仅在不存在映射时写入；数据键加入每个标签集合。
+	// 脚本会读写未通过KEYS传入的键（已有映射的数据键及关联键集合），且KEYS分布在不同槽位，不能用于集群模式，
+	// 集群模式仅通过 setManyCluster 写入
+	setScript = redis.NewScript(`
+local idNameKey = redis.call('GET', KEYS[1])
+if not idNameKey or idNameKey == ARGV[4] then
+	idNameKey = KEYS[3]
+end
+redis.call('SET', KEYS[1], idNameKey, 'PX', ARGV[2])
+redis.call('SET', KEYS[2], idNameKey, 'PX', ARGV[2], 'NX')
+local keysKey = idNameKey .. ARGV[3]
+redis.call('SADD', keysKey, KEYS[1], KEYS[2])
+redis.call('PEXPIRE', keysKey, ARGV[2])
+redis.call('SET', idNameKey, ARGV[1], 'PX', ARGV[2])
+for i = 4, #KEYS do
+	redis.call('SADD', KEYS[i], idNameKey)
+	redis.call('PEXPIRE', KEYS[i], ARGV[2])
+end
+return 1
+`)
+
+	// delScript 原子删除数据、关联键集合及其中的所有映射关系，返回被删除的数据
+	//   - KEYS: keyKey
+	//   - ARGV: 关联键集合后缀, 墓碑标记
+	//
+	// 脚本会读写未通过KEYS传入的键（数据键、关联键集合及其中的映射关系），不能用于集群模式，集群模式仅通过 delCluster 删除
+	delScript = redis.NewScript(`
+local idNameKey = redis.call('GET', KEYS[1])
+if not idNameKey then
+	return false
+end
+if idNameKey == ARGV[2] then
+	redis.call('DEL', KEYS[1])
+	return false
+end
+local keysKey = idNameKey .. ARGV[1]
+local value = redis.call('GET', idNameKey)
+local keys = redis.call('SMEMBERS', keysKey)
+table.insert(keys, KEYS[1])
+table.insert(keys, idNameKey)
+table.insert(keys, keysKey)
+redis.call('DEL', unpack(keys))
+return value
+`)
+)
+
 type (
 	// redisCache Redis缓存结构体
 	redisCache[T any] struct {
-		client redis.UniversalClient
-		ttl    time.Duration
+		client  redis.UniversalClient
+		ttl     time.Duration // 硬过期时间，过期后淘汰
+		softTTL time.Duration // 软过期时间，过期后仍返回数据并触发后台刷新
+		codec   *codec        // 数据和关联键列表的编解码器
+		cluster bool          // 集群模式，跨槽位的键不在同一脚本或命令中操作，不使用 setScript、delScript 等非集群安全的脚本
 	}
 )
 
 // newRedisCache 创建一个新的Redis缓存实例
 func newRedisCache[T any](client redis.UniversalClient, ttl time.Duration) *redisCache[T] {
-	return &redisCache[T]{
+	c := &redisCache[T]{
 		client: client,
 		ttl:    ttl,
+		codec:  newCodec(nil, nil, 0),
 	}
+
+	_, c.cluster = client.(*redis.ClusterClient)
+	return c
 }
 
 // isSet 检查Redis缓存是否已设置
@@ -46,37 +108,42 @@ func (c *redisCache[T]) getType() string {
 
 // get 从Redis缓存中获取数据
 func (c *redisCache[T]) get(ctx context.Context, key any, prefix string) (res result.Interface[T]) {
-	var data T
+	var data payload[T]
 
 	keyKey := c.genKey(prefix, key)
 	idNameKey, err := c.client.Get(ctx, keyKey).Result()
 	if errors.Is(err, redis.Nil) || len(idNameKey) == 0 {
-		return result.Success(data)
+		return result.Success(data.Data)
 	}
 
 	if err != nil {
 		return result.Error[T](fmt.Errorf("GetByRedis: getIdNameKeyByKeyKey, %w", err))
 	}
 
+	if isTombstone(idNameKey) {
+		return negativeResult[T]()
+	}
+
 	value, err := c.client.Get(ctx, idNameKey).Result()
 	if errors.Is(err, redis.Nil) || len(value) == 0 {
-		return result.Success(data)
+		return result.Success(data.Data)
 	}
 
 	if err != nil {
 		return result.Error[T](fmt.Errorf("GetByRedis: %w", err))
 	}
 
-	err = json.Unmarshal([]byte(value), &data)
+	err = c.codec.decode([]byte(value), &data)
 	if err != nil {
-		return result.Error[T](fmt.Errorf("GetByRedis: json.Unmarshal, %w", err))
+		return result.Error[T](fmt.Errorf("GetByRedis: decode, %w", err))
 	}
 
-	return result.Success(data)
+	return data.result()
 }
 
 // set 在Redis中设置一个值，并建立相关的键映射关系
-// 此函数用于缓存数据并维护键之间的映射关系，以支持通过不同类型的键（如主键或ID）访问同一数据
+// 此函数用于缓存数据并维护键之间的映射关系，以支持通过不同类型的键（如主键或ID）访问同一数据。
+// 所有映射关系和数据通过一次Lua脚本原子写入，避免与并发的del交错而残留映射关系
 // 参数:
 //   - ctx: 上下文对象，用于控制请求的生命周期
 //   - key: 主键，可以通过它检索缓存的值
@@ -84,156 +151,258 @@ func (c *redisCache[T]) get(ctx context.Context, key any, prefix string) (res re
 //   - prefix: 键名前缀，用于组织和区分不同业务的缓存
 //   - id: 数据的唯一标识符，也可以用来检索缓存值
 //   - value: 实际要缓存的值
+//   - tags: 数据的标签，数据键加入每个标签集合
 //
 // 返回值:
 //   - result.Interface[T]: 包含操作结果的接口，成功时包含缓存的值，失败时包含错误信息
-func (c *redisCache[T]) set(ctx context.Context, key, name any, prefix string, id any, value T) (res result.Interface[T]) {
-	bytes, err := json.Marshal(value)
+func (c *redisCache[T]) set(ctx context.Context, key, name any, prefix string, id any, value T, tags ...string) (res result.Interface[T]) {
+	if c.cluster {
+		if err := c.setManyCluster(ctx, name, prefix, []item[T]{{key: key, id: id, value: value, tags: tags}}); err != nil {
+			return result.Error[T](fmt.Errorf("SetByRedis: %w", err))
+		}
+		return result.Success(value)
+	}
+
+	keys, args, err := c.setScriptArgs(key, name, prefix, id, value, tags)
 	if err != nil {
 		return result.Error[T](fmt.Errorf("SetByRedis: %w", err))
 	}
 
-	// 设置key->idNameKey映射
-	// 根据传入的key生成Redis键，然后查找对应的idNameKey
-	keyKey := c.genKey(prefix, key)
-	idNameKey, err := c.client.Get(ctx, keyKey).Result()
-	if errors.Is(err, redis.Nil) {
-		err = nil
+	if err = setScript.Run(ctx, c.client, keys, args...).Err(); err != nil {
+		return result.Error[T](fmt.Errorf("SetByRedis: %w", err))
 	}
 
+	return result.Success(value)
+}
+
+// setScriptArgs 生成写入脚本的参数，标签集合键位于keys[3:]
+func (c *redisCache[T]) setScriptArgs(key, name any, prefix string, id any, value T, tags []string) (keys []string, args []any, err error) {
+	bytes, err := c.codec.encode(newPayload(value, c.softTTL))
 	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: getIdNameKeyByKeyKey, %w", err))
+		return
 	}
 
-	// 如果keyKey不存在，则生成新的idNameKey并建立key->idNameKey映射
-	if len(idNameKey) == 0 {
-		idNameKey = c.genKey(prefix, name, id)
-		_, err = c.client.Set(ctx, keyKey, idNameKey, c.ttl).Result()
-		if err != nil {
-			return result.Error[T](fmt.Errorf("SetByRedis: setIdNameKeyByKeyKey, %w", err))
-		}
+	keys = []string{c.genKey(prefix, key), c.genKey(prefix, id), c.genEntityKey(prefix, name, id)}
+	for _, tag := range tags {
+		keys = append(keys, c.genTagKey(prefix, tag))
+	}
+	args = []any{string(bytes), c.ttl.Milliseconds(), keysKeySuffix, tombstone}
+	return
+}
+
+// del 从Redis缓存中删除数据，以及与该数据关联的所有键和墓碑标记，通过一次Lua脚本原子删除
+func (c *redisCache[T]) del(ctx context.Context, key any, prefix string) (res result.Interface[T]) {
+	var (
+		data  payload[T]
+		value string
+		err   error
+	)
+
+	if c.cluster {
+		value, err = c.delCluster(ctx, c.genKey(prefix, key))
+	} else {
+		value, err = delScript.Run(ctx, c.client, []string{c.genKey(prefix, key)}, keysKeySuffix, tombstone).Text()
 	}
 
-	// 设置id->idNameKey映射
-	// 根据传入的id生成Redis键，然后建立id->idNameKey映射
-	idKey := c.genKey(prefix, id)
-	idNameKy, err := c.client.Get(ctx, idKey).Result()
-	if errors.Is(err, redis.Nil) {
-		err = nil
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](fmt.Errorf("DelByRedis: %w", err))
 	}
 
+	if len(value) == 0 {
+		return result.Success(data.Data)
+	}
+
+	// 数据已删除，解码失败时不影响删除结果
+	_ = c.codec.decode([]byte(value), &data)
+
+	return result.Success(data.Data)
+}
+
+// getMany 从Redis缓存中批量获取数据，仅返回命中的键，超过软过期时间的数据标记为stale，命中墓碑标记的键返回负缓存结果
+// 通过两次pipeline分别批量获取 key->idNameKey 映射和 idNameKey->value 数据
+func (c *redisCache[T]) getMany(ctx context.Context, keys []any, prefix string) (res result.Interface[map[any]result.Interface[T]]) {
+	data := make(map[any]result.Interface[T], len(keys))
+	if len(keys) == 0 {
+		return result.Success(data)
+	}
+
+	keyKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		keyKeys = append(keyKeys, c.genKey(prefix, key))
+	}
+
+	idNameKeys, err := c.mget(ctx, keyKeys)
 	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: getIdNameKeyByIdKey, %w", err))
+		return result.Error[map[any]result.Interface[T]](fmt.Errorf("GetManyByRedis: getIdNameKeysByKeyKeys, %w", err))
 	}
 
-	// 如果idKey不存在对应的idNameKey，则建立id->idNameKey映射
-	if len(idNameKy) == 0 {
-		_, err = c.client.Set(ctx, idKey, idNameKey, c.ttl).Result()
-		if err != nil {
-			return result.Error[T](fmt.Errorf("SetByRedis: setIdNameKeyByIdKey, %w", err))
+	// 仅查询存在映射关系的键，命中墓碑标记的键返回负缓存结果
+	hitKeys := make([]any, 0, len(keys))
+	hitIdNameKeys := make([]string, 0, len(keys))
+	for i, idNameKey := range idNameKeys {
+		switch {
+		case isTombstone(idNameKey):
+			data[keys[i]] = negativeResult[T]()
+		case len(idNameKey) > 0:
+			hitKeys = append(hitKeys, keys[i])
+			hitIdNameKeys = append(hitIdNameKeys, idNameKey)
 		}
 	}
 
-	// 更新关联键列表
-	// 获取与idNameKey相关的所有键列表，用于后续批量删除操作
-	keysRes, err := c.client.Get(ctx, idNameKey).Result()
-	if errors.Is(err, redis.Nil) {
-		err = nil
+	if len(hitIdNameKeys) == 0 {
+		return result.Success(data)
 	}
 
+	values, err := c.mget(ctx, hitIdNameKeys)
 	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: getKeysByIdNameKey, %w", err))
+		return result.Error[map[any]result.Interface[T]](fmt.Errorf("GetManyByRedis: %w", err))
 	}
 
-	var keys []string
-	if len(keysRes) > 0 {
-		err = json.Unmarshal([]byte(keysRes), &keys)
-		if err != nil {
-			return result.Error[T](fmt.Errorf("SetByRedis: json.Unmarshal, %w", err))
+	for i, value := range values {
+		if len(value) == 0 {
+			continue
+		}
+
+		var v payload[T]
+		if err = c.codec.decode([]byte(value), &v); err != nil {
+			return result.Error[map[any]result.Interface[T]](fmt.Errorf("GetManyByRedis: decode, %w", err))
 		}
+		data[hitKeys[i]] = v.result()
 	}
 
-	// 将keyKey和idKey添加到关联键列表中，如果它们还不存在的话
-	for _, v := range []string{keyKey, idKey} {
-		if !slices.Contains(keys, v) {
-			keys = append(keys, v)
+	return result.Success(data)
+}
+
+// setMany 将数据批量设置到Redis缓存中，并建立相关的键映射关系
+// 每条数据通过写入脚本原子写入，所有脚本在一次pipeline中执行
+func (c *redisCache[T]) setMany(ctx context.Context, name any, prefix string, items []item[T]) (res result.Interface[map[any]T]) {
+	data := make(map[any]T, len(items))
+	if len(items) == 0 {
+		return result.Success(data)
+	}
+
+	if c.cluster {
+		if err := c.setManyCluster(ctx, name, prefix, items); err != nil {
+			return result.Error[map[any]T](fmt.Errorf("SetManyByRedis: %w", err))
 		}
+		for _, v := range items {
+			data[v.key] = v.value
+		}
+		return result.Success(data)
 	}
 
-	keysBytes, err := json.Marshal(keys)
-	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: json.Marshal, %w", err))
+	type scriptArgs struct {
+		keys []string
+		args []any
 	}
 
-	keysKey := c.genKey(prefix, idNameKey)
-	_, err = c.client.Set(ctx, keysKey, string(keysBytes), c.ttl).Result()
-	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: SetKeys, %w", err))
+	list := make([]scriptArgs, 0, len(items))
+	for _, v := range items {
+		keys, args, err := c.setScriptArgs(v.key, name, prefix, v.id, v.value, v.tags)
+		if err != nil {
+			return result.Error[map[any]T](fmt.Errorf("SetManyByRedis: %w", err))
+		}
+		list = append(list, scriptArgs{keys: keys, args: args})
+		data[v.key] = v.value
 	}
 
-	// 设置实际值
-	// 将序列化的值存储在idNameKey下，这是实际数据的存储位置
-	_, err = c.client.Set(ctx, idNameKey, string(bytes), c.ttl).Result()
+	err := c.pipelined(ctx, []*redis.Script{setScript}, func(pipe redis.Pipeliner) {
+		for _, v := range list {
+			setScript.EvalSha(ctx, pipe, v.keys, v.args...)
+		}
+	})
 	if err != nil {
-		return result.Error[T](fmt.Errorf("SetByRedis: %w", err))
+		return result.Error[map[any]T](fmt.Errorf("SetManyByRedis: %w", err))
 	}
 
-	return result.Success(value)
+	return result.Success(data)
 }
 
-// del 从Redis缓存中删除数据
-func (c *redisCache[T]) del(ctx context.Context, key any, prefix string) (res result.Interface[T]) {
-	getRes := c.get(ctx, key, prefix)
-	if getRes.Err() != nil {
-		return getRes
+// setTombstone 在Redis缓存中写入墓碑标记，使用独立的过期时间
+func (c *redisCache[T]) setTombstone(ctx context.Context, key any, prefix string, ttl time.Duration) (res result.Interface[T]) {
+	_, err := c.client.Set(ctx, c.genKey(prefix, key), tombstone, ttl).Result()
+	if err != nil {
+		return result.Error[T](fmt.Errorf("SetTombstoneByRedis: %w", err))
 	}
 
-	// 获取要删除的idNameKey
-	keyKey := c.genKey(prefix, key)
-	idNameKey, err := c.client.Get(ctx, keyKey).Result()
-	if errors.Is(err, redis.Nil) {
-		err = nil
-	}
+	var data T
+	return result.Success(data)
+}
 
+// setTombstones 通过一次pipeline在Redis中批量写入墓碑标记
+func (c *redisCache[T]) setTombstones(ctx context.Context, keys []any, prefix string, ttl time.Duration) (res result.Interface[int]) {
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Set(ctx, c.genKey(prefix, key), tombstone, ttl)
+		}
+		return nil
+	})
 	if err != nil {
-		return result.Error[T](fmt.Errorf("DelByRedis: getIdNameKeyByKeyKey, %w", err))
+		return result.Error[int](fmt.Errorf("SetTombstonesByRedis: %w", err))
 	}
 
-	var data T
-	if len(idNameKey) == 0 {
-		return result.Success(data)
+	return result.Success(len(keys))
+}
+
+// pipelined 执行包含脚本（EVALSHA）的pipeline，脚本未加载时加载脚本后重新执行一次
+func (c *redisCache[T]) pipelined(ctx context.Context, scripts []*redis.Script, fn func(pipe redis.Pipeliner)) error {
+	exec := func() error {
+		_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			fn(pipe)
+			return nil
+		})
+		return err
 	}
 
-	// 获取关联键列表
-	keysKey := c.genKey(prefix, idNameKey)
-	keysRes, err := c.client.Get(ctx, keysKey).Result()
-	if errors.Is(err, redis.Nil) {
-		err = nil
+	err := exec()
+	if err == nil || !redis.HasErrorPrefix(err, "NOSCRIPT") {
+		return err
 	}
 
-	if err != nil {
-		return result.Error[T](fmt.Errorf("DelByRedis: getKeysByIdNameKey, %w", err))
+	for _, script := range scripts {
+		if err = script.Load(ctx, c.client).Err(); err != nil {
+			return err
+		}
 	}
 
-	var keys []string
-	if len(keysRes) > 0 {
-		err = json.Unmarshal([]byte(keysRes), &keys)
-		if err != nil {
-			return result.Error[T](fmt.Errorf("DelByRedis: json.Unmarshal, %w", err))
+	return exec()
+}
+
+// mget 通过pipeline分批执行MGET，返回与keys一一对应的值，不存在的键对应空字符串。
+// 集群模式下键可能分布在不同槽位，改为pipeline逐个GET
+func (c *redisCache[T]) mget(ctx context.Context, keys []string) ([]string, error) {
+	if c.cluster {
+		return c.getPipelined(ctx, keys)
+	}
+
+	cmds := make([]*redis.SliceCmd, 0, len(keys)/mgetBatchSize+1)
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for batch := range slices.Chunk(keys, mgetBatchSize) {
+			cmds = append(cmds, pipe.MGet(ctx, batch...))
 		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
 	}
 
-	// 删除所有相关键
-	delKeys := append(keys, idNameKey, keysKey)
-	_, err = c.client.Del(ctx, delKeys...).Result()
-	if err != nil {
-		return result.Error[T](fmt.Errorf("DelByRedis: %w", err))
+	values := make([]string, 0, len(keys))
+	for _, cmd := range cmds {
+		for _, v := range cmd.Val() {
+			s, _ := v.(string)
+			values = append(values, s)
+		}
 	}
 
-	return result.Success(getRes.Data())
+	return values, nil
 }
 
 // genKey 生成缓存键
 func (c *redisCache[T]) genKey(prefix string, data ...any) string {
 	return fmt.Sprintf("%s%s", prefix, crypto.MD5(data))
 }
+
+// genEntityKey 生成数据键（idNameKey），使用hashtag包裹，使数据键及其关联键集合位于同一槽位
+func (c *redisCache[T]) genEntityKey(prefix string, data ...any) string {
+	return fmt.Sprintf("%s{%s}", prefix, crypto.MD5(data))
+}
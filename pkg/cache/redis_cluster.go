@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	clusterSlots = 16384 // Redis Cluster槽位数量
+)
+
+var (
+	// setEntityScript 集群模式下原子写入数据及其关联键集合（位于同一槽位）
+	//   - KEYS: idNameKey, keysKey
+	//   - ARGV: 编码后的数据, ttl（毫秒）, keyKey, idKey
+	setEntityScript = redis.NewScript(`
+redis.call('SADD', KEYS[2], ARGV[3], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+	// delEntityScript 集群模式下原子删除数据及其关联键集合（位于同一槽位），返回 [数据, 关联键...]
+	//   - KEYS: idNameKey, keysKey
+	delEntityScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+local keys = redis.call('SMEMBERS', KEYS[2])
+redis.call('DEL', KEYS[1], KEYS[2])
+table.insert(keys, 1, value or '')
+return keys
+`)
+)
+
+// setManyCluster 集群模式下批量写入数据。
+// keyKey、idKey与数据键位于不同槽位，先通过pipeline读取keyKey已有的映射，
+// 再通过一次pipeline按槽位写入别名映射，数据和关联键集合通过脚本在数据键所在槽位原子写入
+func (c *redisCache[T]) setManyCluster(ctx context.Context, name any, prefix string, items []item[T]) error {
+	type entry struct {
+		keyKey    string
+		idKey     string
+		idNameKey string
+		value     string
+		tagKeys   []string
+	}
+
+	entries := make([]entry, 0, len(items))
+	keyKeys := make([]string, 0, len(items))
+	for _, v := range items {
+		keys, args, err := c.setScriptArgs(v.key, name, prefix, v.id, v.value, v.tags)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{keyKey: keys[0], idKey: keys[1], idNameKey: keys[2], value: args[0].(string), tagKeys: keys[3:]})
+		keyKeys = append(keyKeys, keys[0])
+	}
+
+	// keyKey已映射到其他数据键时沿用原映射
+	idNameKeys, err := c.getPipelined(ctx, keyKeys)
+	if err != nil {
+		return fmt.Errorf("getIdNameKeysByKeyKeys, %w", err)
+	}
+
+	for i, idNameKey := range idNameKeys {
+		if len(idNameKey) > 0 && !isTombstone(idNameKey) {
+			entries[i].idNameKey = idNameKey
+		}
+	}
+
+	return c.pipelined(ctx, []*redis.Script{setEntityScript}, func(pipe redis.Pipeliner) {
+		for _, v := range entries {
+			pipe.Set(ctx, v.keyKey, v.idNameKey, c.ttl)
+			pipe.SetNX(ctx, v.idKey, v.idNameKey, c.ttl)
+			setEntityScript.EvalSha(ctx, pipe, []string{v.idNameKey, v.idNameKey + keysKeySuffix},
+				v.value, c.ttl.Milliseconds(), v.keyKey, v.idKey)
+			for _, tagKey := range v.tagKeys {
+				pipe.SAdd(ctx, tagKey, v.idNameKey)
+				pipe.PExpire(ctx, tagKey, c.ttl)
+			}
+		}
+	})
+}
+
+// delCluster 集群模式下删除数据，返回被删除的数据。
+// 数据和关联键集合通过脚本在数据键所在槽位原子删除，keyKey和其他别名键按槽位分组删除
+func (c *redisCache[T]) delCluster(ctx context.Context, keyKey string) (value string, err error) {
+	idNameKey, err := c.client.Get(ctx, keyKey).Result()
+	if err != nil {
+		return
+	}
+
+	if isTombstone(idNameKey) {
+		return "", c.client.Del(ctx, keyKey).Err()
+	}
+
+	res, err := delEntityScript.Run(ctx, c.client, []string{idNameKey, idNameKey + keysKeySuffix}).StringSlice()
+	if err != nil {
+		return
+	}
+
+	keys := []string{keyKey}
+	if len(res) > 0 {
+		value, keys = res[0], append(keys, res[1:]...)
+	}
+
+	err = c.delBySlot(ctx, keys)
+	return
+}
+
+// delBySlot 将键按槽位分组，通过一次pipeline对每个槽位执行一次DEL
+func (c *redisCache[T]) delBySlot(ctx context.Context, keys []string) error {
+	slots := make(map[int][]string)
+	for _, key := range keys {
+		slot := keySlot(key)
+		slots[slot] = append(slots[slot], key)
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, v := range slots {
+			pipe.Del(ctx, v...)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// getPipelined 通过pipeline逐个GET，返回与keys一一对应的值，不存在的键对应空字符串
+func (c *redisCache[T]) getPipelined(ctx context.Context, keys []string) ([]string, error) {
+	cmds := make([]*redis.StringCmd, 0, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			cmds = append(cmds, pipe.Get(ctx, key))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd.Val())
+	}
+
+	return values, nil
+}
+
+// keySlot 计算键所在的Redis Cluster槽位，键中包含hashtag（{...}）时仅计算hashtag部分
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 Redis Cluster使用的CRC16（XMODEM）校验
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
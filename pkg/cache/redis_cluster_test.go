@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testCluster 使用多个miniredis节点模拟Redis Cluster，按槽位范围将命令路由到不同节点
+type testCluster struct {
+	nodes  []*miniredis.Miniredis
+	ranges []redis.ClusterSlot
+}
+
+func newTestCluster(t *testing.T, n int) *testCluster {
+	t.Helper()
+
+	tc := &testCluster{}
+	step := clusterSlots / n
+	for i := 0; i < n; i++ {
+		node := miniredis.RunT(t)
+		end := (i+1)*step - 1
+		if i == n-1 {
+			end = clusterSlots - 1
+		}
+		tc.nodes = append(tc.nodes, node)
+		tc.ranges = append(tc.ranges, redis.ClusterSlot{
+			Start: i * step,
+			End:   end,
+			Nodes: []redis.ClusterNode{{Addr: node.Addr()}},
+		})
+	}
+
+	return tc
+}
+
+func (tc *testCluster) client(t *testing.T) *redis.ClusterClient {
+	t.Helper()
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return tc.ranges, nil
+		},
+	})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+// misplaced 返回不在所属槽位节点上的键
+func (tc *testCluster) misplaced() (keys []string) {
+	for i, node := range tc.nodes {
+		for _, key := range node.Keys() {
+			if slot := keySlot(key); slot < tc.ranges[i].Start || slot > tc.ranges[i].End {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return
+}
+
+// keys 返回所有节点上的键
+func (tc *testCluster) keys() (keys []string) {
+	for _, node := range tc.nodes {
+		keys = append(keys, node.Keys()...)
+	}
+	return
+}
+
+func TestKeySlot(t *testing.T) {
+	if slot := keySlot("123456789"); slot != 12739 {
+		t.Fatalf("keySlot(123456789) = %d, want 12739", slot)
+	}
+
+	if keySlot("{user1000}.following") != keySlot("{user1000}.followers") {
+		t.Fatal("keys with the same hashtag should share a slot")
+	}
+
+	if keySlot("foo{}{bar}") != int(crc16("foo{}{bar}")%clusterSlots) {
+		t.Fatal("empty hashtag should hash the whole key")
+	}
+}
+
+func TestRedisCacheCluster(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestCluster(t, 3)
+	c := newRedisCache[benchUser](tc.client(t), time.Minute)
+	if !c.cluster {
+		t.Fatal("cluster mode should be detected from *redis.ClusterClient")
+	}
+
+	users := []benchUser{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "c"}}
+	for _, user := range users {
+		if res := c.set(ctx, user.Name, "user", "test:", user.ID, user); res.Err() != nil {
+			t.Fatalf("set: %v", res.Err())
+		}
+	}
+
+	// 批量写入别名键
+	items := make([]item[benchUser], 0, len(users))
+	for _, user := range users {
+		items = append(items, item[benchUser]{key: "alias:" + user.Name, id: user.ID, value: user, tags: []string{"all"}})
+	}
+	if res := c.setMany(ctx, "user", "test:", items); res.Err() != nil {
+		t.Fatalf("setMany: %v", res.Err())
+	}
+
+	if keys := tc.misplaced(); len(keys) > 0 {
+		t.Fatalf("keys stored on the wrong node: %v", keys)
+	}
+
+	getRes := c.getMany(ctx, []any{"a", "alias:b", users[2].ID}, "test:")
+	if getRes.Err() != nil || len(getRes.Data()) != 3 {
+		t.Fatalf("getMany: %+v, %v", getRes.Data(), getRes.Err())
+	}
+
+	for _, user := range users[:2] {
+		if res := c.del(ctx, "alias:"+user.Name, "test:"); res.Err() != nil || res.Data() != user {
+			t.Fatalf("del: %+v, %v", res.Data(), res.Err())
+		}
+	}
+
+	// 标签删除剩余数据，已删除的数据不计数
+	if res := c.invalidateTag(ctx, "all", "test:"); res.Err() != nil || res.Data() != 1 {
+		t.Fatalf("invalidateTag: %d, %v", res.Data(), res.Err())
+	}
+
+	if keys := tc.keys(); len(keys) > 0 {
+		t.Fatalf("dangling keys after del: %v", keys)
+	}
+
+	// 墓碑标记
+	if res := c.setTombstone(ctx, "missing", "test:", time.Minute); res.Err() != nil {
+		t.Fatalf("setTombstone: %v", res.Err())
+	}
+	if res := c.get(ctx, "missing", "test:"); !IsNegative(res) {
+		t.Fatal("tombstone should be returned as negative result")
+	}
+	if res := c.del(ctx, "missing", "test:"); res.Err() != nil {
+		t.Fatalf("del tombstone: %v", res.Err())
+	}
+	if keys := tc.keys(); len(keys) > 0 {
+		t.Fatalf("dangling keys after del tombstone: %v", keys)
+	}
+
+	// 集群模式不使用访问未声明键的脚本
+	for _, node := range tc.nodes {
+		client := redis.NewClient(&redis.Options{Addr: node.Addr()})
+		exists, err := client.ScriptExists(ctx, setScript.Hash(), delScript.Hash(), delTagMembersScript.Hash()).Result()
+		_ = client.Close()
+		if err != nil || slices.Contains(exists, true) {
+			t.Fatalf("non cluster safe scripts loaded: %v, %v", exists, err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/redis/go-redis/v9"
+)
+
+type benchUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// newTestRedisCache 创建测试用的Redis缓存，设置环境变量 CACHE_TEST_REDIS_ADDR 时使用真实Redis，否则使用miniredis。
+// miniredis在进程内执行且没有网络往返，Lua脚本为解释执行，对比新旧写入路径的耗时请使用真实Redis
+func newTestRedisCache(tb testing.TB) *redisCache[benchUser] {
+	tb.Helper()
+
+	addr := os.Getenv("CACHE_TEST_REDIS_ADDR")
+	if len(addr) == 0 {
+		addr = miniredis.RunT(tb).Addr()
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	tb.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return newRedisCache[benchUser](client, time.Minute)
+}
+
+func TestRedisCacheSetDel(t *testing.T) {
+	ctx := context.Background()
+	c := newTestRedisCache(t)
+	user := benchUser{ID: 1, Name: "song"}
+
+	if res := c.set(ctx, "user:1", "user", "test:", user.ID, user); res.Err() != nil {
+		t.Fatalf("set: %v", res.Err())
+	}
+
+	// 通过ID别名写入同一数据，关联键集合应包含两个键
+	if res := c.set(ctx, "mobile:1", "user", "test:", user.ID, user); res.Err() != nil {
+		t.Fatalf("set alias: %v", res.Err())
+	}
+
+	for _, key := range []any{"user:1", "mobile:1", user.ID} {
+		if res := c.get(ctx, key, "test:"); res.Err() != nil || res.Data() != user {
+			t.Fatalf("get %v: %+v, %v", key, res.Data(), res.Err())
+		}
+	}
+
+	if res := c.del(ctx, "mobile:1", "test:"); res.Err() != nil || res.Data() != user {
+		t.Fatalf("del: %+v, %v", res.Data(), res.Err())
+	}
+
+	keys, err := c.client.Keys(ctx, "test:*").Result()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+
+	if len(keys) != 0 {
+		t.Fatalf("dangling keys after del: %v", keys)
+	}
+}
+
+func TestRedisCacheDelError(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	for _, cluster := range []bool{false, true} {
+		c := newRedisCache[benchUser](client, time.Minute)
+		c.cluster = cluster
+
+		// 不存在的键视为删除成功
+		if res := c.del(ctx, "user:1", "test:"); res.Err() != nil {
+			t.Fatalf("cluster=%v del missing: %v", cluster, res.Err())
+		}
+
+		// Redis出错时返回错误，以便重试
+		m.SetError("server down")
+		res := c.del(ctx, "user:1", "test:")
+		m.SetError("")
+		if res.Err() == nil {
+			t.Fatalf("cluster=%v del returned success on redis error", cluster)
+		}
+	}
+}
+
+func TestRedisCacheInvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	c := newTestRedisCache(t)
+	users := []benchUser{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "c"}}
+
+	for _, user := range users[:2] {
+		if res := c.set(ctx, user.Name, "user", "test:", user.ID, user, "team:1"); res.Err() != nil {
+			t.Fatalf("set: %v", res.Err())
+		}
+	}
+	if res := c.set(ctx, users[2].Name, "user", "test:", users[2].ID, users[2], "team:2"); res.Err() != nil {
+		t.Fatalf("set: %v", res.Err())
+	}
+
+	if res := c.invalidateTag(ctx, "team:1", "test:"); res.Err() != nil || res.Data() != 2 {
+		t.Fatalf("invalidateTag: %d, %v", res.Data(), res.Err())
+	}
+
+	for _, user := range users[:2] {
+		if res := c.get(ctx, user.Name, "test:"); res.Err() != nil || res.Data() != (benchUser{}) {
+			t.Fatalf("get %s after invalidateTag: %+v, %v", user.Name, res.Data(), res.Err())
+		}
+	}
+
+	if res := c.get(ctx, users[2].Name, "test:"); res.Err() != nil || res.Data() != users[2] {
+		t.Fatalf("get untagged: %+v, %v", res.Data(), res.Err())
+	}
+
+	if res := c.del(ctx, users[2].Name, "test:"); res.Err() != nil {
+		t.Fatalf("del: %v", res.Err())
+	}
+
+	keys, err := c.client.Keys(ctx, "test:*").Result()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+
+	// 标签集合随成员删除而清空，team:2 的集合在数据过期后随TTL淘汰
+	if len(keys) != 1 || keys[0] != c.genTagKey("test:", "team:2") {
+		t.Fatalf("dangling keys after invalidateTag: %v", keys)
+	}
+}
+
+func BenchmarkRedisCacheSet(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("legacy", func(b *testing.B) {
+		c := newTestRedisCache(b)
+		for i := 0; b.Loop(); i++ {
+			if res := legacySet(ctx, c, fmt.Sprintf("user:%d", i%1000), "user", "bench:", int64(i%1000), benchUser{ID: int64(i % 1000)}); res.Err() != nil {
+				b.Fatal(res.Err())
+			}
+		}
+	})
+
+	b.Run("lua", func(b *testing.B) {
+		c := newTestRedisCache(b)
+		for i := 0; b.Loop(); i++ {
+			if res := c.set(ctx, fmt.Sprintf("user:%d", i%1000), "user", "bench:", int64(i%1000), benchUser{ID: int64(i % 1000)}); res.Err() != nil {
+				b.Fatal(res.Err())
+			}
+		}
+	})
+}
+
+func BenchmarkRedisCacheSetDel(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("legacy", func(b *testing.B) {
+		c := newTestRedisCache(b)
+		for i := 0; b.Loop(); i++ {
+			key := fmt.Sprintf("user:%d", i)
+			_ = legacySet(ctx, c, key, "user", "bench:", int64(i), benchUser{ID: int64(i)})
+			if res := legacyDel(ctx, c, key, "bench:"); res.Err() != nil {
+				b.Fatal(res.Err())
+			}
+		}
+	})
+
+	b.Run("lua", func(b *testing.B) {
+		c := newTestRedisCache(b)
+		for i := 0; b.Loop(); i++ {
+			key := fmt.Sprintf("user:%d", i)
+			_ = c.set(ctx, key, "user", "bench:", int64(i), benchUser{ID: int64(i)})
+			if res := c.del(ctx, key, "bench:"); res.Err() != nil {
+				b.Fatal(res.Err())
+			}
+		}
+	})
+}
+
+// legacySet 改为Lua脚本之前的写入路径，逐条命令读写映射关系和数据，仅用于基准测试对比
+func legacySet[T any](ctx context.Context, c *redisCache[T], key, name any, prefix string, id any, value T) result.Interface[T] {
+	bytes, err := c.codec.encode(newPayload(value, c.softTTL))
+	if err != nil {
+		return result.Error[T](err)
+	}
+
+	keyKey := c.genKey(prefix, key)
+	idNameKey, err := c.client.Get(ctx, keyKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](err)
+	}
+
+	if len(idNameKey) == 0 {
+		idNameKey = c.genKey(prefix, name, id)
+		if err = c.client.Set(ctx, keyKey, idNameKey, c.ttl).Err(); err != nil {
+			return result.Error[T](err)
+		}
+	}
+
+	idKey := c.genKey(prefix, id)
+	idNameKy, err := c.client.Get(ctx, idKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](err)
+	}
+
+	if len(idNameKy) == 0 {
+		if err = c.client.Set(ctx, idKey, idNameKey, c.ttl).Err(); err != nil {
+			return result.Error[T](err)
+		}
+	}
+
+	keysKey := c.genKey(prefix, idNameKey)
+	keysRes, err := c.client.Get(ctx, keysKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](err)
+	}
+
+	var keys []string
+	if len(keysRes) > 0 {
+		if err = c.codec.decode([]byte(keysRes), &keys); err != nil {
+			return result.Error[T](err)
+		}
+	}
+
+	for _, v := range []string{keyKey, idKey} {
+		if !slices.Contains(keys, v) {
+			keys = append(keys, v)
+		}
+	}
+
+	keysBytes, err := c.codec.encode(keys)
+	if err != nil {
+		return result.Error[T](err)
+	}
+
+	if err = c.client.Set(ctx, keysKey, string(keysBytes), c.ttl).Err(); err != nil {
+		return result.Error[T](err)
+	}
+
+	if err = c.client.Set(ctx, idNameKey, string(bytes), c.ttl).Err(); err != nil {
+		return result.Error[T](err)
+	}
+
+	return result.Success(value)
+}
+
+// legacyDel 改为Lua脚本之前的删除路径，先读取数据和关联键列表再删除，仅用于基准测试对比
+func legacyDel[T any](ctx context.Context, c *redisCache[T], key any, prefix string) result.Interface[T] {
+	getRes := c.get(ctx, key, prefix)
+	if getRes.Err() != nil {
+		return getRes
+	}
+
+	keyKey := c.genKey(prefix, key)
+	idNameKey, err := c.client.Get(ctx, keyKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](err)
+	}
+
+	if len(idNameKey) == 0 {
+		return getRes
+	}
+
+	keysKey := c.genKey(prefix, idNameKey)
+	keysRes, err := c.client.Get(ctx, keysKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return result.Error[T](err)
+	}
+
+	var keys []string
+	if len(keysRes) > 0 {
+		if err = c.codec.decode([]byte(keysRes), &keys); err != nil {
+			return result.Error[T](err)
+		}
+	}
+
+	if err = c.client.Del(ctx, append(keys, idNameKey, keysKey)...).Err(); err != nil {
+		return result.Error[T](err)
+	}
+
+	return getRes
+}
+
+func TestRedisCacheLegacyPayload(t *testing.T) {
+	ctx := context.Background()
+	c := newTestRedisCache(t)
+	user := benchUser{ID: 1, Name: "song"}
+
+	if res := c.set(ctx, "user:1", "user", "test:", user.ID, user); res.Err() != nil {
+		t.Fatalf("set: %v", res.Err())
+	}
+
+	// 引入payload之前存储的T的原始JSON，以及不带编码头部的payload
+	raw, _ := json.Marshal(user)
+	values := map[string]bool{string(raw): false, `{"d":{"id":1,"name":"song"},"s":1}`: true}
+	for value, stale := range values {
+		if err := c.client.Set(ctx, c.genEntityKey("test:", "user", user.ID), value, time.Minute).Err(); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+
+		if res := c.get(ctx, "user:1", "test:"); res.Err() != nil || res.Data() != user || IsStale(res) != stale {
+			t.Fatalf("get %s: %+v, %v", value, res.Data(), res.Err())
+		}
+	}
+}
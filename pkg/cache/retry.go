@@ -15,26 +15,41 @@ type retryDoRequest[K any, V any] struct {
 	cache        *Cache[V]
 }
 
-// retryDo 执行带重试机制的缓存操作
+// retryDo 执行带重试机制的缓存操作，未开启重试时只执行一次，singleflight仍生效
 func retryDo[K any, V any](ctx context.Context, request *retryDoRequest[K, V]) (res result.Interface[K]) {
 	if request == nil || request.cache == nil {
 		return result.Error[K](fmt.Errorf("retryDoRequest is invalid"))
 	}
 
-	// 未开启重试
-	if !request.cache.retryConf.enable {
+	// 未开启重试且未启用singleflight
+	if !request.cache.retryConf.enable && !request.singleflight {
 		return request.handler(ctx)
 	}
 
-	// 开启重试
-	opts := make([]retry.Option, 0, len(request.cache.retryConf.options)+1)
-	opts = append(opts, request.cache.retryConf.options...)
+	opts := make([]retry.Option, 0, len(request.cache.retryConf.options)+2)
+	if request.cache.retryConf.enable {
+		opts = append(opts, request.cache.retryConf.options...)
+	} else {
+		opts = append(opts, retry.Num(1))
+	}
 
-	if request.singleflight { // 启用singleflight
-		opts = append(opts, retry.SingleflightKey(request.key))
-	} else { // 未启用singleflight
+	if !request.singleflight { // 未启用singleflight
 		opts = append(opts, retry.SingleflightKey(""))
+		return retry.Do(ctx, request.handler, opts...)
+	}
+
+	// 启用singleflight，handler未在当前调用中执行时说明共享了其他调用的结果
+	executed := false
+	handler := func(ctx context.Context) result.Interface[K] {
+		executed = true
+		return request.handler(ctx)
 	}
 
-	return retry.Do(ctx, request.handler, opts...)
-}
\ No newline at end of file
+	opts = append(opts, retry.SingleflightKey(request.key))
+	res = retry.Do(ctx, handler, opts...)
+	if !executed {
+		request.cache.stats.observeShared(ctx)
+	}
+
+	return res
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"go.uber.org/zap"
+)
+
+const (
+	LayerLoader = "loader" // 回源统计的层级名称
+
+	EventHit         EventKind = "hit"          // 缓存命中
+	EventMiss        EventKind = "miss"         // 缓存未命中，Layer为空时表示所有层级均未命中
+	EventError       EventKind = "error"        // 缓存读取失败
+	EventEviction    EventKind = "eviction"     // 缓存数据容量不足淘汰
+	EventKeyEviction EventKind = "key_eviction" // LRU映射关系容量不足淘汰
+	EventTagEviction EventKind = "tag_eviction" // LRU标签集合容量不足淘汰
+	EventLoad        EventKind = "load"         // 回源成功
+	EventLoadError   EventKind = "load_error"   // 回源失败
+	EventShared      EventKind = "shared"       // singleflight共享了其他调用的结果
+)
+
+var (
+	// latencyBounds 耗时直方图的桶上界，最后一个桶为 +Inf
+	latencyBounds = []time.Duration{
+		100 * time.Microsecond,
+		500 * time.Microsecond,
+		time.Millisecond,
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		100 * time.Millisecond,
+		500 * time.Millisecond,
+		time.Second,
+	}
+)
+
+type (
+	// EventKind 统计事件类型
+	EventKind string
+
+	// Event 统计事件，通过 StatsHook 上报
+	Event struct {
+		Layer   string        // 缓存层级：TypeLRU、TypeRedis、LayerLoader，所有层级均未命中和singleflight共享时为空
+		Kind    EventKind     // 事件类型
+		Count   uint64        // 事件次数，批量操作时大于1
+		Latency time.Duration // 操作耗时，无耗时的事件为0
+	}
+
+	// StatsHook 统计事件钩子，可用于导出监控指标，OnEvent 在调用方协程中同步执行，不应阻塞
+	StatsHook interface {
+		OnEvent(ctx context.Context, event Event)
+	}
+
+	// Stats 缓存统计快照
+	Stats struct {
+		LRU                LayerStats // LRU缓存统计
+		Redis              LayerStats // Redis缓存统计
+		Misses             uint64     // 所有层级均未命中次数
+		LoaderCalls        uint64     // 回源次数
+		LoaderErrors       uint64     // 回源失败次数
+		LoaderLatency      Histogram  // 回源耗时
+		SingleflightShared uint64     // singleflight共享其他调用结果的次数
+	}
+
+	// LayerStats 单个缓存层级的统计快照
+	LayerStats struct {
+		Hits         uint64    // 命中次数
+		Misses       uint64    // 未命中次数
+		Errors       uint64    // 读取失败次数
+		Evictions    uint64    // 数据容量不足淘汰次数
+		KeyEvictions uint64    // 映射关系容量不足淘汰次数，仅LRU
+		TagEvictions uint64    // 标签集合容量不足淘汰次数，仅LRU
+		Latency      Histogram // 读取耗时
+	}
+
+	// Histogram 耗时直方图快照
+	Histogram struct {
+		Bounds []time.Duration // 桶上界
+		Counts []uint64        // 各桶计数，长度为len(Bounds)+1，最后一个桶为 +Inf
+		Count  uint64          // 总次数
+		Sum    time.Duration   // 总耗时
+	}
+
+	// stats 缓存统计计数器
+	stats struct {
+		lru           layerStats
+		redis         layerStats
+		misses        atomic.Uint64
+		loaderCalls   atomic.Uint64
+		loaderErrors  atomic.Uint64
+		loaderLatency histogram
+		shared        atomic.Uint64
+		hook          StatsHook
+	}
+
+	layerStats struct {
+		hits         atomic.Uint64
+		misses       atomic.Uint64
+		errors       atomic.Uint64
+		evictions    atomic.Uint64
+		keyEvictions atomic.Uint64
+		tagEvictions atomic.Uint64
+		latency      histogram
+	}
+
+	histogram struct {
+		counts []atomic.Uint64
+		count  atomic.Uint64
+		sum    atomic.Int64
+	}
+)
+
+// newStats 创建缓存统计计数器
+func newStats(hook StatsHook) *stats {
+	s := &stats{hook: hook}
+	for _, h := range []*histogram{&s.lru.latency, &s.redis.latency, &s.loaderLatency} {
+		h.counts = make([]atomic.Uint64, len(latencyBounds)+1)
+	}
+	return s
+}
+
+// layer 获取缓存层级的计数器
+func (s *stats) layer(layer string) *layerStats {
+	if layer == TypeLRU {
+		return &s.lru
+	}
+	return &s.redis
+}
+
+// observeGet 记录一次缓存读取，hits/misses为批量读取中命中和未命中的键数量
+func (s *stats) observeGet(ctx context.Context, layer string, hits, misses int, err error, latency time.Duration) {
+	l := s.layer(layer)
+	l.latency.observe(latency)
+
+	if err != nil {
+		l.errors.Add(1)
+		s.emit(ctx, Event{Layer: layer, Kind: EventError, Count: 1, Latency: latency})
+		return
+	}
+
+	if hits > 0 {
+		l.hits.Add(uint64(hits))
+		s.emit(ctx, Event{Layer: layer, Kind: EventHit, Count: uint64(hits), Latency: latency})
+	}
+
+	if misses > 0 {
+		l.misses.Add(uint64(misses))
+		s.emit(ctx, Event{Layer: layer, Kind: EventMiss, Count: uint64(misses), Latency: latency})
+	}
+}
+
+// observeMiss 记录所有层级均未命中
+func (s *stats) observeMiss(ctx context.Context, count int) {
+	s.misses.Add(uint64(count))
+	s.emit(ctx, Event{Kind: EventMiss, Count: uint64(count)})
+}
+
+// observeLoad 记录一次回源
+func (s *stats) observeLoad(ctx context.Context, err error, latency time.Duration) {
+	s.loaderCalls.Add(1)
+	s.loaderLatency.observe(latency)
+
+	if err != nil {
+		s.loaderErrors.Add(1)
+		s.emit(ctx, Event{Layer: LayerLoader, Kind: EventLoadError, Count: 1, Latency: latency})
+		return
+	}
+
+	s.emit(ctx, Event{Layer: LayerLoader, Kind: EventLoad, Count: 1, Latency: latency})
+}
+
+// observeShared 记录一次singleflight共享
+func (s *stats) observeShared(ctx context.Context) {
+	s.shared.Add(1)
+	s.emit(ctx, Event{Kind: EventShared, Count: 1})
+}
+
+// observeEviction 记录一次缓存容量不足淘汰，kind区分淘汰的是数据、映射关系还是标签集合
+func (s *stats) observeEviction(layer string, kind EventKind) {
+	l := s.layer(layer)
+	switch kind {
+	case EventKeyEviction:
+		l.keyEvictions.Add(1)
+	case EventTagEviction:
+		l.tagEvictions.Add(1)
+	default:
+		l.evictions.Add(1)
+	}
+	s.emit(context.Background(), Event{Layer: layer, Kind: kind, Count: 1})
+}
+
+// emit 上报统计事件
+func (s *stats) emit(ctx context.Context, event Event) {
+	if s.hook != nil {
+		s.hook.OnEvent(ctx, event)
+	}
+}
+
+// snapshot 获取统计快照
+func (s *stats) snapshot() Stats {
+	return Stats{
+		LRU:                s.lru.snapshot(),
+		Redis:              s.redis.snapshot(),
+		Misses:             s.misses.Load(),
+		LoaderCalls:        s.loaderCalls.Load(),
+		LoaderErrors:       s.loaderErrors.Load(),
+		LoaderLatency:      s.loaderLatency.snapshot(),
+		SingleflightShared: s.shared.Load(),
+	}
+}
+
+func (l *layerStats) snapshot() LayerStats {
+	return LayerStats{
+		Hits:         l.hits.Load(),
+		Misses:       l.misses.Load(),
+		Errors:       l.errors.Load(),
+		Evictions:    l.evictions.Load(),
+		KeyEvictions: l.keyEvictions.Load(),
+		TagEvictions: l.tagEvictions.Load(),
+		Latency:      l.latency.snapshot(),
+	}
+}
+
+// observe 记录一次耗时
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	counts := make([]uint64, len(h.counts))
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+	}
+
+	return Histogram{
+		Bounds: latencyBounds,
+		Counts: counts,
+		Count:  h.count.Load(),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+}
+
+// HitRate 命中率，无读取时返回0
+func (l LayerStats) HitRate() float64 {
+	total := l.Hits + l.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(l.Hits) / float64(total)
+}
+
+// Stats 获取缓存统计快照
+func (c *Cache[T]) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// LogStats 通过erlogs记录缓存统计快照，name为缓存名称
+func (c *Cache[T]) LogStats(ctx context.Context, name string) {
+	s := c.Stats()
+	erlogs.New("cache stats").InfoLog(ctx, erlogs.OptionFields(
+		zap.String("cache", name),
+		zap.String("key_prefix", c.keyPrefix),
+		zap.Uint64("lru_hits", s.LRU.Hits),
+		zap.Uint64("lru_misses", s.LRU.Misses),
+		zap.Uint64("lru_evictions", s.LRU.Evictions),
+		zap.Uint64("lru_key_evictions", s.LRU.KeyEvictions),
+		zap.Uint64("lru_tag_evictions", s.LRU.TagEvictions),
+		zap.Float64("lru_hit_rate", s.LRU.HitRate()),
+		zap.Uint64("redis_hits", s.Redis.Hits),
+		zap.Uint64("redis_misses", s.Redis.Misses),
+		zap.Uint64("redis_errors", s.Redis.Errors),
+		zap.Float64("redis_hit_rate", s.Redis.HitRate()),
+		zap.Uint64("misses", s.Misses),
+		zap.Uint64("loader_calls", s.LoaderCalls),
+		zap.Uint64("loader_errors", s.LoaderErrors),
+		zap.Duration("loader_latency_sum", s.LoaderLatency.Sum),
+		zap.Uint64("singleflight_shared", s.SingleflightShared),
+	))
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/result"
+)
+
+type (
+	recordHook struct {
+		mu     sync.Mutex
+		events map[eventKey]uint64
+	}
+
+	eventKey struct {
+		layer string
+		kind  EventKind
+	}
+)
+
+func (h *recordHook) OnEvent(ctx context.Context, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events[eventKey{event.Layer, event.Kind}] += event.Count
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	hook := &recordHook{events: make(map[eventKey]uint64)}
+	c := newTestCache(t, nil, Statistics[benchUser](hook))
+
+	loader := func(ctx context.Context) result.Interface[benchUser] {
+		return result.Success(benchUser{ID: 1, Name: "a"})
+	}
+
+	// 第一次各级均未命中并回源，第二次命中LRU
+	_ = c.GetOrSet(ctx, "user", "user:1", loader)
+	_ = c.GetOrSet(ctx, "user", "user:1", loader)
+	_ = c.GetOrSet(ctx, "user", "user:2", func(ctx context.Context) result.Interface[benchUser] {
+		return result.Error[benchUser](errors.New("failed"))
+	})
+
+	s := c.Stats()
+	if s.LRU.Hits != 1 || s.LRU.Misses != 2 || s.LRU.HitRate() != 1.0/3 || s.Redis.Misses != 2 || s.Redis.Hits != 0 {
+		t.Fatalf("layer stats: %+v, %+v", s.LRU, s.Redis)
+	}
+
+	if s.Misses != 2 || s.LoaderCalls != 2 || s.LoaderErrors != 1 || s.LoaderLatency.Count != 2 || s.LRU.Latency.Count != 3 {
+		t.Fatalf("stats: %+v", s)
+	}
+
+	want := map[eventKey]uint64{
+		{TypeLRU, EventHit}:           1,
+		{TypeLRU, EventMiss}:          2,
+		{TypeRedis, EventMiss}:        2,
+		{"", EventMiss}:               2,
+		{LayerLoader, EventLoad}:      1,
+		{LayerLoader, EventLoadError}: 1,
+	}
+	for kind, count := range want {
+		if hook.events[kind] != count {
+			t.Fatalf("events: %v, want %v = %d", hook.events, kind, count)
+		}
+	}
+}
+
+func TestStatsEvictions(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, nil, LRUCache[benchUser](1, time.Minute))
+
+	// 数据容量为1，keyClient容量为4，写入两条数据时数据和映射关系均被淘汰
+	for i := int64(1); i <= 2; i++ {
+		_ = c.lruCache.set(ctx, i, "user", c.keyPrefix, i, benchUser{ID: i})
+	}
+	s := c.Stats().LRU
+	if s.Evictions != 1 {
+		t.Fatalf("evictions = %d, want 1", s.Evictions)
+	}
+
+	// 同一数据的多个别名只占用映射关系的容量，映射关系淘汰单独计数
+	for i := 0; i < 8; i++ {
+		_ = c.lruCache.set(ctx, "alias:"+strconv.Itoa(i), "user", c.keyPrefix, int64(2), benchUser{ID: 2})
+	}
+	if got := c.Stats().LRU; got.Evictions != s.Evictions || got.KeyEvictions <= s.KeyEvictions {
+		t.Fatalf("evictions = %d, key evictions = %d", got.Evictions, got.KeyEvictions)
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tagScanBatchSize = 100 // 删除标签时每批SSCAN的成员数量
+)
+
+var (
+	// delTagMembersScript 原子删除一批打上标签的数据，返回被删除的数据数量。
+	// 别名键仅在仍映射到该数据键时删除，避免误删已重新映射到其他数据的别名
+	//   - KEYS: tagKey, idNameKey...
+	//   - ARGV: 关联键集合后缀
+	//
+	// 脚本会读写未通过KEYS传入的键（关联键集合及其中的映射关系），不能用于集群模式，集群模式通过 delEntityScript 逐个删除
+	delTagMembersScript = redis.NewScript(`
+local count = 0
+for i = 2, #KEYS do
+	local idNameKey = KEYS[i]
+	local keysKey = idNameKey .. ARGV[1]
+	for _, key in ipairs(redis.call('SMEMBERS', keysKey)) do
+		if redis.call('GET', key) == idNameKey then
+			redis.call('DEL', key)
+		end
+	end
+	count = count + redis.call('DEL', idNameKey)
+	redis.call('DEL', keysKey)
+	redis.call('SREM', KEYS[1], idNameKey)
+end
+return count
+`)
+
+	// delIfEqualScript 键的值等于ARGV[1]时删除该键
+	//   - KEYS: key
+	//   - ARGV: 期望的值
+	delIfEqualScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// InvalidateTag 删除多级缓存中打上tag的所有数据及其关联键，返回被删除的数据数量，
+// 并通过Redis pub/sub通知其他实例淘汰本地LRU缓存中打上该tag的数据
+//
+// 若开启redis cache，返回Redis中被删除的数据数量，否则返回LRU缓存中被删除的数据数量
+//
+// 参数:
+//   - ctx: 上下文对象，用于传递超时、取消信号等
+//   - tag: 标签，写入缓存时通过 GetOrSet/GetOrSetMany 的tags参数设置
+//
+// 返回值:
+//   - result.Interface[int]: 被删除的数据数量，Redis删除失败时返回错误
+func (c *Cache[T]) InvalidateTag(ctx context.Context, tag string) (res result.Interface[int]) {
+	res = result.Success(0)
+
+	for _, cache := range []cacheInterface[T]{c.lruCache, c.redisCache} {
+		if cache == nil || !cache.isSet() {
+			continue
+		}
+
+		handler := func(ctx context.Context) result.Interface[int] {
+			return cache.invalidateTag(ctx, tag, c.keyPrefix)
+		}
+
+		if cache.isRetryEnable() {
+			res = retryDo(ctx, &retryDoRequest[int, T]{
+				key:          cache.genKey(c.keyPrefix, tag, "invalidatetag"),
+				singleflight: false,
+				handler:      handler,
+				cache:        c,
+			})
+		} else {
+			res = handler(ctx)
+		}
+
+		if res.Err() != nil {
+			return
+		}
+	}
+
+	c.publishTags(ctx, tag)
+
+	return
+}
+
+// genTagKey 生成标签集合键
+func (c *lruCache[T]) genTagKey(prefix, tag string) string {
+	return c.genKey(prefix, "tag", tag)
+}
+
+// addTags 将数据键加入每个标签集合，同时清理已被淘汰的数据键
+func (c *lruCache[T]) addTags(prefix, idNameKey string, tags []string) {
+	for _, tag := range tags {
+		tagKey := c.genTagKey(prefix, tag)
+		members, _ := c.tagClient.Get(tagKey)
+
+		list := make([]string, 0, len(members)+1)
+		for _, v := range members {
+			if v != idNameKey && c.valueClient.Contains(v) {
+				list = append(list, v)
+			}
+		}
+
+		// 标签集合容量不足淘汰时记录标签集合淘汰次数
+		if c.tagClient.Add(tagKey, append(list, idNameKey)) && c.stats != nil {
+			c.stats.observeEviction(TypeLRU, EventTagEviction)
+		}
+	}
+}
+
+// invalidateTag 删除LRU缓存中打上tag的所有数据及其关联键
+func (c *lruCache[T]) invalidateTag(ctx context.Context, tag string, prefix string) (res result.Interface[int]) {
+	return result.Success(c.invalidateTagKey(c.genTagKey(prefix, tag)))
+}
+
+// invalidateTagKey 根据genTagKey生成的标签集合键删除LRU缓存数据，返回被删除的数据数量
+func (c *lruCache[T]) invalidateTagKey(tagKey string) (count int) {
+	members, ok := c.tagClient.Peek(tagKey)
+	if !ok {
+		return
+	}
+
+	_ = c.tagClient.Remove(tagKey)
+	for _, idNameKey := range members {
+		if c.delByIdNameKey(idNameKey) {
+			count++
+		}
+	}
+
+	return
+}
+
+// genTagKey 生成标签集合键，集合成员为打上该标签的数据键
+func (c *redisCache[T]) genTagKey(prefix, tag string) string {
+	return c.genKey(prefix, "tag", tag)
+}
+
+// invalidateTag 分批SSCAN标签集合，删除Redis缓存中打上tag的所有数据及其关联键。
+// 已删除的数据键从集合中移除，集合为空时由Redis自动删除，删除过程中新写入的数据不受影响
+func (c *redisCache[T]) invalidateTag(ctx context.Context, tag string, prefix string) (res result.Interface[int]) {
+	tagKey := c.genTagKey(prefix, tag)
+
+	var (
+		count  int
+		cursor uint64
+	)
+
+	for {
+		members, next, err := c.client.SScan(ctx, tagKey, cursor, "", tagScanBatchSize).Result()
+		if err != nil {
+			return result.New(count, fmt.Errorf("InvalidateTagByRedis: sscan, %w", err))
+		}
+
+		if len(members) > 0 {
+			n, err := c.delTagMembers(ctx, tagKey, members)
+			count += n
+			if err != nil {
+				return result.New(count, fmt.Errorf("InvalidateTagByRedis: %w", err))
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return result.Success(count)
+		}
+	}
+}
+
+// delTagMembers 删除一批打上标签的数据，返回被删除的数据数量。
+// 集群模式下数据键、别名键和标签集合键位于不同槽位，数据和关联键集合通过脚本在数据键所在槽位原子删除，
+// 别名键通过脚本逐个校验映射后删除
+func (c *redisCache[T]) delTagMembers(ctx context.Context, tagKey string, members []string) (count int, err error) {
+	if !c.cluster {
+		count, err = delTagMembersScript.Run(ctx, c.client, append([]string{tagKey}, members...), keysKeySuffix).Int()
+		return
+	}
+
+	for _, idNameKey := range members {
+		res, err := delEntityScript.Run(ctx, c.client, []string{idNameKey, idNameKey + keysKeySuffix}).StringSlice()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return count, err
+		}
+
+		if len(res) == 0 {
+			continue
+		}
+
+		if len(res[0]) > 0 {
+			count++
+		}
+
+		err = c.pipelined(ctx, []*redis.Script{delIfEqualScript}, func(pipe redis.Pipeliner) {
+			for _, key := range res[1:] {
+				delIfEqualScript.EvalSha(ctx, pipe, []string{key}, idNameKey)
+			}
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+
+	err = c.client.SRem(ctx, tagKey, members).Err()
+	return
+}
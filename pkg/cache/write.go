@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/mel0dys0ng/song/pkg/retry"
+	"go.uber.org/zap"
+)
+
+const (
+	WriteThrough WriteMode = iota // 先持久化，成功后更新各级缓存，默认模式
+	WriteBehind                   // 先更新各级缓存，再通过队列异步持久化
+	WriteAround                   // 先持久化，成功后删除缓存，下次读取时回源
+
+	writeBehindSizeDefault         = 1024             // 异步持久化队列的默认容量
+	writeBehindFlushTimeoutDefault = 5 * time.Second  // 单条数据持久化（含重试）的默认超时时间
+	writeBehindCloseTimeoutDefault = 30 * time.Second // Close等待队列持久化完成的默认最长时间
+)
+
+var (
+	ErrWriteBehindClosed          = errors.New("cache write-behind queue closed")
+	ErrWriteBehindKeyIncomparable = errors.New("cache write-behind key is not comparable")
+)
+
+type (
+	// WriteMode 写入模式
+	WriteMode int
+
+	// PersistFunc 持久化函数，将数据写入数据源
+	PersistFunc[T any] func(ctx context.Context, value T) error
+
+	// WriteErrorFunc 异步持久化重试后仍失败时的回调，未配置时通过erlogs记录错误日志
+	WriteErrorFunc[T any] func(ctx context.Context, key any, value T, err error)
+
+	// writeConf 写入配置
+	writeConf[T any] struct {
+		mode         WriteMode         // 写入模式
+		size         int               // 异步持久化队列容量，按键合并，容量为不同键的数量
+		onError      WriteErrorFunc[T] // 异步持久化失败回调
+		options      []retry.Option    // 异步持久化重试配置
+		flushTimeout time.Duration     // 单条数据持久化（含重试）的超时时间
+		closeTimeout time.Duration     // Close等待队列持久化完成的最长时间
+		queue        *writeQueue[T]    // 异步持久化队列
+	}
+
+	// writeQueue 有界且按键合并的异步持久化队列，同一键排队期间的多次写入只持久化最后一次
+	writeQueue[T any] struct {
+		mu      sync.Mutex
+		entries map[any]writeEntry[T] // 排队中的数据
+		order   []any                 // 键的入队顺序
+		slots   chan struct{}         // 空闲容量，新键入队时占用，出队时释放
+		notify  chan struct{}         // 有新数据入队
+		closed  bool                  // 是否已关闭
+		cancel  context.CancelFunc    // 停止持久化协程
+		abort   context.CancelFunc    // 取消正在进行和剩余的持久化
+		stopped chan struct{}         // 持久化协程已退出
+	}
+
+	writeEntry[T any] struct {
+		value   T
+		persist PersistFunc[T]
+	}
+)
+
+// Set 按写入模式写入数据，persist用于将数据写入数据源
+//
+//   - 1）WriteThrough：先调用persist持久化，成功后更新各级缓存，persist失败时不更新缓存；
+//   - 2）WriteBehind：先预留异步持久化队列容量（队列已满时阻塞直到有空闲容量或ctx取消），再更新各级缓存并入队，入队失败时不保留缓存；
+//     同一键排队期间的多次写入只持久化最后一次，持久化按WriteBehindQueue的重试配置重试，仍失败时调用错误回调；
+//     队列按键合并，key的动态类型必须可比较（不能为或包含slice、map、func），否则返回 ErrWriteBehindKeyIncomparable；
+//   - 3）WriteAround：先调用persist持久化，成功后删除各级缓存。
+//
+// 更新或删除缓存后通过Redis pub/sub通知其他实例淘汰本地LRU缓存。
+//
+// 参数:
+//   - ctx: 上下文对象，用于传递超时、取消信号等
+//   - name: 缓存名称，用于区分不同业务
+//   - key: 缓存键，支持任意类型
+//   - value: 要写入的数据
+//   - persist: 持久化函数
+//   - tags: 数据的标签
+//
+// 返回值:
+//   - result.Interface[T]: 写入的数据，持久化（WriteBehind为入队）或写缓存失败时返回错误
+func (c *Cache[T]) Set(ctx context.Context, name, key any, value T, persist PersistFunc[T], tags ...string) (res result.Interface[T]) {
+	switch c.write.mode {
+	case WriteAround:
+		if err := persist(ctx, value); err != nil {
+			return result.Error[T](fmt.Errorf("persist: %w", err))
+		}
+
+		if res = c.Del(ctx, key); res.Err() != nil {
+			return
+		}
+		return result.Success(value)
+	case WriteBehind:
+		if !reflect.ValueOf(key).Comparable() {
+			return result.Error[T](ErrWriteBehindKeyIncomparable)
+		}
+
+		// 写缓存前预留队列容量，避免缓存中出现不会被持久化的数据
+		reserved, err := c.write.queue.reserve(ctx, key)
+		if err != nil {
+			return result.Error[T](err)
+		}
+
+		if res = c.setLayers(ctx, name, key, value, tags); res.Err() != nil {
+			c.write.queue.release(reserved)
+			return
+		}
+
+		if err = c.write.queue.commit(ctx, key, value, persist, reserved); err != nil {
+			// 预留后队列被关闭等情况下无法入队，删除已写入的缓存
+			_ = c.Del(context.WithoutCancel(ctx), key)
+			return result.Error[T](err)
+		}
+		return result.Success(value)
+	default:
+		if err := persist(ctx, value); err != nil {
+			return result.Error[T](fmt.Errorf("persist: %w", err))
+		}
+		return c.setLayers(ctx, name, key, value, tags)
+	}
+}
+
+// setLayers 将数据写入各级缓存，并通知其他实例淘汰旧的LRU缓存
+func (c *Cache[T]) setLayers(ctx context.Context, name, key any, value T, tags []string) (res result.Interface[T]) {
+	res = c.set(ctx, []cacheInterface[T]{c.lruCache, c.redisCache}, name, key,
+		func(ctx context.Context) result.Interface[T] {
+			return result.Success(value)
+		},
+		tags...,
+	)
+	if res.Err() != nil {
+		return
+	}
+
+	if c.isZero(value) {
+		c.publish(ctx, key)
+	} else {
+		c.publish(ctx, key, c.dataId(value))
+	}
+
+	return result.Success(value)
+}
+
+// startWrite WriteBehind模式下启动异步持久化协程
+func (c *Cache[T]) startWrite() {
+	if c.write.mode != WriteBehind {
+		return
+	}
+
+	if c.write.size <= 0 {
+		c.write.size = writeBehindSizeDefault
+	}
+
+	if c.write.flushTimeout <= 0 {
+		c.write.flushTimeout = writeBehindFlushTimeoutDefault
+	}
+
+	if c.write.closeTimeout <= 0 {
+		c.write.closeTimeout = writeBehindCloseTimeoutDefault
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	flushCtx, abort := context.WithCancel(context.Background())
+	c.write.queue = &writeQueue[T]{
+		entries: make(map[any]writeEntry[T], c.write.size),
+		slots:   make(chan struct{}, c.write.size),
+		notify:  make(chan struct{}, 1),
+		cancel:  cancel,
+		abort:   abort,
+		stopped: make(chan struct{}),
+	}
+
+	go c.flushLoop(ctx, flushCtx)
+}
+
+// flushLoop 持续持久化队列中的数据，ctx取消后持久化剩余数据再退出；flushCtx取消后剩余数据的持久化立即失败
+func (c *Cache[T]) flushLoop(ctx, flushCtx context.Context) {
+	q := c.write.queue
+	defer close(q.stopped)
+
+	for {
+		for c.flushOne(flushCtx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			for c.flushOne(flushCtx) {
+			}
+			return
+		case <-q.notify:
+		}
+	}
+}
+
+// flushOne 持久化队列中最早入队的数据，持久化（含重试）超过flushTimeout时取消，队列为空时返回false
+func (c *Cache[T]) flushOne(ctx context.Context) bool {
+	key, entry, ok := c.write.queue.pop()
+	if !ok {
+		return false
+	}
+
+	persistCtx, cancel := context.WithTimeout(ctx, c.write.flushTimeout)
+	defer cancel()
+
+	opts := append([]retry.Option{}, c.write.options...)
+	res := retry.Do(persistCtx, func(ctx context.Context) result.Interface[any] {
+		return result.Error[any](entry.persist(ctx, entry.value))
+	}, append(opts, retry.SingleflightKey(""))...)
+
+	// 未配置错误回调时记录错误日志
+	if err := res.Err(); err != nil {
+		if c.write.onError != nil {
+			c.write.onError(ctx, key, entry.value, err)
+		} else {
+			erlogs.Convert(err).Wrap("cache write-behind persist failed").ErrorLog(ctx,
+				erlogs.OptionFields(zap.String("key_prefix", c.keyPrefix), zap.Any("key", key)),
+			)
+		}
+	}
+
+	return true
+}
+
+// reserve 检查队列未关闭并预留容量：键已在队列中时合并入队无需容量，返回false；否则等待空闲容量，返回true
+func (q *writeQueue[T]) reserve(ctx context.Context, key any) (reserved bool, err error) {
+	q.mu.Lock()
+	closed, queued := q.closed, q.has(key)
+	q.mu.Unlock()
+
+	if closed {
+		return false, ErrWriteBehindClosed
+	}
+
+	if queued {
+		return false, nil
+	}
+
+	return true, q.acquire(ctx)
+}
+
+// acquire 等待空闲容量
+func (q *writeQueue[T]) acquire(ctx context.Context) error {
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("write-behind queue full: %w", context.Cause(ctx))
+	}
+}
+
+// release 释放预留的容量
+func (q *writeQueue[T]) release(reserved bool) {
+	if reserved {
+		<-q.slots
+	}
+}
+
+// commit 使用 reserve 预留的容量将数据放入队列，键已在队列中时合并为最新数据并释放预留容量。
+// 未预留容量而键已出队时重新等待空闲容量
+func (q *writeQueue[T]) commit(ctx context.Context, key any, value T, persist PersistFunc[T], reserved bool) error {
+	q.mu.Lock()
+	switch {
+	case q.closed:
+		q.mu.Unlock()
+		q.release(reserved)
+		return ErrWriteBehindClosed
+	case q.has(key):
+		q.release(reserved)
+	case !reserved:
+		q.mu.Unlock()
+		if err := q.acquire(ctx); err != nil {
+			return err
+		}
+		return q.commit(ctx, key, value, persist, true)
+	default:
+		q.order = append(q.order, key)
+	}
+	q.entries[key] = writeEntry[T]{value: value, persist: persist}
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (q *writeQueue[T]) has(key any) bool {
+	_, ok := q.entries[key]
+	return ok
+}
+
+// pop 取出最早入队的数据并释放容量
+func (q *writeQueue[T]) pop() (key any, entry writeEntry[T], ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return
+	}
+
+	key, q.order = q.order[0], q.order[1:]
+	entry, ok = q.entries[key]
+	delete(q.entries, key)
+	<-q.slots
+
+	return
+}
+
+// close 停止接收新数据，等待队列中的数据全部持久化，最多等待timeout。
+// 超时后取消正在进行和剩余的持久化，剩余数据交由错误回调处理，不响应ctx取消的持久化函数不再等待
+func (q *writeQueue[T]) close(timeout time.Duration) {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.cancel()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-q.stopped:
+	case <-timer.C:
+		q.abort()
+	}
+}
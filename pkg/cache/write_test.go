@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/retry"
+)
+
+func newTestWriteCache(opts ...Option[benchUser]) *Cache[benchUser] {
+	return New(append([]Option[benchUser]{
+		LRUCache[benchUser](100, time.Minute),
+		KeyPrefix[benchUser]("test:"),
+		IsZero(func(data benchUser) bool { return data.ID == 0 }),
+		DataId(func(data benchUser) any { return data.ID }),
+	}, opts...)...)
+}
+
+func TestCacheSetWriteThroughAndAround(t *testing.T) {
+	ctx := context.Background()
+	user := benchUser{ID: 1, Name: "song"}
+	errPersist := errors.New("persist failed")
+
+	c := newTestWriteCache()
+	fail := func(ctx context.Context, value benchUser) error { return errPersist }
+	if res := c.Set(ctx, "user", "user:1", user, fail); !errors.Is(res.Err(), errPersist) {
+		t.Fatalf("Set: %v, want %v", res.Err(), errPersist)
+	}
+	if res := c.lruCache.get(ctx, "user:1", c.keyPrefix); res.Data() != (benchUser{}) {
+		t.Fatalf("cache updated after persist failed: %+v", res.Data())
+	}
+
+	ok := func(ctx context.Context, value benchUser) error { return nil }
+	if res := c.Set(ctx, "user", "user:1", user, ok); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+	if res := c.lruCache.get(ctx, "user:1", c.keyPrefix); res.Data() != user {
+		t.Fatalf("write-through cache: %+v", res.Data())
+	}
+
+	c = newTestWriteCache(Write[benchUser](WriteAround))
+	_ = c.lruCache.set(ctx, "user:1", "user", c.keyPrefix, user.ID, user)
+	if res := c.Set(ctx, "user", "user:1", benchUser{ID: 1, Name: "new"}, ok); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+	if res := c.lruCache.get(ctx, "user:1", c.keyPrefix); res.Data() != (benchUser{}) {
+		t.Fatalf("write-around should invalidate cache: %+v", res.Data())
+	}
+}
+
+func TestCacheSetWriteBehind(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mu        sync.Mutex
+		persisted []string
+		failed    []string
+	)
+	gate := make(chan struct{})
+	persist := func(ctx context.Context, value benchUser) error {
+		<-gate
+		if value.ID == 2 {
+			return errors.New("persist failed")
+		}
+		mu.Lock()
+		persisted = append(persisted, value.Name)
+		mu.Unlock()
+		return nil
+	}
+	onError := func(ctx context.Context, key any, value benchUser, err error) {
+		mu.Lock()
+		failed = append(failed, value.Name)
+		mu.Unlock()
+	}
+
+	c := newTestWriteCache(
+		Write[benchUser](WriteBehind),
+		WriteBehindQueue[benchUser](2, onError, retry.Num(1)),
+	)
+
+	// 第一次写入被持久化协程取出后阻塞，之后同一键的写入在队列中合并
+	if res := c.Set(ctx, "user", "user:1", benchUser{ID: 1, Name: "v1"}, persist); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range []string{"v2", "v3"} {
+		if res := c.Set(ctx, "user", "user:1", benchUser{ID: 1, Name: name}, persist); res.Err() != nil {
+			t.Fatalf("Set: %v", res.Err())
+		}
+	}
+	if res := c.Set(ctx, "user", "user:2", benchUser{ID: 2, Name: "fail"}, persist); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+
+	// 缓存立即更新
+	if res := c.lruCache.get(ctx, "user:1", c.keyPrefix); res.Data().Name != "v3" {
+		t.Fatalf("write-behind cache: %+v", res.Data())
+	}
+
+	// 队列已满（user:1、user:2），新键写入阻塞直到ctx超时
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if res := c.Set(timeoutCtx, "user", "user:3", benchUser{ID: 3, Name: "v1"}, persist); res.Err() == nil {
+		t.Fatal("Set should fail when the queue is full")
+	}
+	// 入队失败时不写入缓存
+	if res := c.lruCache.get(ctx, "user:3", c.keyPrefix); res.Data() != (benchUser{}) {
+		t.Fatalf("cache updated after enqueue failed: %+v", res.Data())
+	}
+
+	close(gate)
+	c.Close()
+
+	if !slices.Equal(persisted, []string{"v1", "v3"}) {
+		t.Fatalf("persisted = %v, want [v1 v3]", persisted)
+	}
+	if !slices.Equal(failed, []string{"fail"}) {
+		t.Fatalf("failed = %v, want [fail]", failed)
+	}
+
+	ok := func(ctx context.Context, value benchUser) error { return nil }
+	if res := c.Set(ctx, "user", "user:1", benchUser{ID: 1}, ok); !errors.Is(res.Err(), ErrWriteBehindClosed) {
+		t.Fatalf("Set after Close: %v, want %v", res.Err(), ErrWriteBehindClosed)
+	}
+	if res := c.lruCache.get(ctx, "user:1", c.keyPrefix); res.Data().Name != "v3" {
+		t.Fatalf("cache updated after Close: %+v", res.Data())
+	}
+}
+
+func TestCacheSetWriteBehindTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	errs := make(chan error, 2)
+	onError := func(ctx context.Context, key any, value benchUser, err error) {
+		errs <- err
+	}
+
+	c := newTestWriteCache(
+		Write[benchUser](WriteBehind),
+		WriteBehindQueue[benchUser](2, onError, retry.Num(1)),
+		WriteBehindTimeout[benchUser](20*time.Millisecond, 50*time.Millisecond),
+	)
+
+	// 持久化超过flush超时时间后取消，交由错误回调处理
+	wait := func(ctx context.Context, value benchUser) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if res := c.Set(ctx, "user", "user:1", benchUser{ID: 1}, wait); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+	if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("onError: %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// 持久化函数不响应ctx取消时，Close最多等待close超时时间
+	gate := make(chan struct{})
+	defer close(gate)
+	hang := func(ctx context.Context, value benchUser) error {
+		<-gate
+		return nil
+	}
+	if res := c.Set(ctx, "user", "user:2", benchUser{ID: 2}, hang); res.Err() != nil {
+		t.Fatalf("Set: %v", res.Err())
+	}
+
+	start := time.Now()
+	c.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Close took %v", elapsed)
+	}
+
+	// 不可比较的键无法在队列中合并
+	c = newTestWriteCache(Write[benchUser](WriteBehind))
+	defer c.Close()
+	if res := c.Set(ctx, "user", []int{1}, benchUser{ID: 1}, hang); !errors.Is(res.Err(), ErrWriteBehindKeyIncomparable) {
+		t.Fatalf("Set: %v, want %v", res.Err(), ErrWriteBehindKeyIncomparable)
+	}
+}
@@ -15,6 +15,8 @@ type (
 	ClientInfo           = https.ClientInfo
 	ResponseData         = https.ResponseData
 	ResponseOption       = https.ResponseOption
+	RateLimitConfig      = https.RateLimit
+	RateLimitRule        = https.RateLimitRule
 )
 
 // Constants
@@ -74,6 +76,11 @@ const (
 	DefaultSignSecret = https.DefaultSignSecret
 	DefaultSignTTL    = https.DefaultSignTTL
 
+	// Rate limit constants
+	RateLimitKeyRoute  = https.RateLimitKeyRoute
+	RateLimitKeyIP     = https.RateLimitKeyIP
+	RateLimitKeyDevice = https.RateLimitKeyDevice
+
 	// Config constants
 	DefaultPort              = https.DefaultPort
 	DefaultHost              = https.DefaultHost
@@ -124,6 +131,11 @@ func ResponseError(ctx *gin.Context, err error, opts ...https.ResponseOption) {
 	https.ResponseError(ctx, err, opts...)
 }
 
+// ResponseErrorWithStatus 指定http status的请求失败响应，响应体与 ResponseError 相同
+func ResponseErrorWithStatus(ctx *gin.Context, status int, err error, opts ...https.ResponseOption) {
+	https.ResponseErrorWithStatus(ctx, status, err, opts...)
+}
+
 // ResponseWithStatus 自定义http status响应，默认JSON格式
 func ResponseWithStatus(ctx *gin.Context, status int, opts ...https.ResponseOption) {
 	https.ResponseWithStatus(ctx, status, opts...)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mel0dys0ng/song/internal/core/https"
+	"github.com/mel0dys0ng/song/pkg/ratelimit"
 	"github.com/samber/lo"
 )
 
@@ -158,3 +159,27 @@ func Middlewares(middlewares ...https.Middleware) Option {
 		options.Middlewares = append(options.Middlewares, middlewares...)
 	}
 }
+
+// RateLimit 设置限流配置，覆盖配置文件中的 rateLimit
+func RateLimit(config *https.RateLimit) Option {
+	return func(options *https.Options) {
+		options.RateLimit = config
+	}
+}
+
+// RateLimiter 设置自定义限流器，默认根据 RateLimit.Redis 使用Redis或进程内限流器
+func RateLimiter(limiter ratelimit.Limiter) Option {
+	return func(options *https.Options) {
+		options.RateLimiter = limiter
+	}
+}
+
+// RateLimitKeyFunc 注册自定义限流维度的提取函数，限流规则的Key为name时使用
+func RateLimitKeyFunc(name string, fn https.RateLimitKeyFunc) Option {
+	return func(options *https.Options) {
+		if options.RateLimitKeyFuncs == nil {
+			options.RateLimitKeyFuncs = make(map[string]https.RateLimitKeyFunc)
+		}
+		options.RateLimitKeyFuncs[name] = fn
+	}
+}
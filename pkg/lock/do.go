@@ -0,0 +1,102 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrorLockLost = errors.New("lock lost")
+)
+
+type (
+	// LockLostError 持锁执行期间锁续期失败或锁已被其他持有者获取，可通过 errors.As 与业务函数的错误区分，
+	// errors.Is(err, ErrorLockLost) 同样成立
+	LockLostError struct {
+		Key string // 锁的key
+		Err error  // 锁丢失的原因
+	}
+)
+
+func (e *LockLostError) Error() string {
+	return fmt.Sprintf("lock %s lost: %v", e.Key, e.Err)
+}
+
+func (e *LockLostError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LockLostError) Is(target error) bool {
+	return target == ErrorLockLost
+}
+
+// Do 获取锁后执行fn，执行完毕（包括fn panic）后释放锁，fn的panic在释放锁后继续向上传递。
+// fn的ctx在锁续期失败或锁已被其他持有者获取时取消，context.Cause 返回 *LockLostError；
+// 此时 Do 返回 *LockLostError，而不是fn的错误。锁丢失检测依赖锁续期，需配置TTL大于1秒
+//
+// 参数:
+//
+//	ctx: 上下文，用于控制获取锁和fn的生命周期。
+//	l: 分布式锁实例。
+//	key: 要锁定的资源唯一标识符，不可为空。
+//	fn: 持锁执行的函数。
+//
+// 返回值:
+//
+//	err: 获取锁失败的错误（锁被占用返回 ErrorLockAcquiredByOther）、锁丢失返回 *LockLostError、
+//	fn返回的错误，以及释放锁的错误。
+func Do(ctx context.Context, l *Lock, key string, fn func(ctx context.Context) error) (err error) {
+	c, ok, err := l.Lock(ctx, key)
+	if err != nil {
+		return
+	}
+
+	if !ok {
+		return ErrorLockAcquiredByOther
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// 监听续期失败通知，Unlock 关闭通道后退出
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		if er, ok := <-c.C; ok {
+			cancel(&LockLostError{Key: key, Err: er})
+		}
+	}()
+
+	// 使用defer释放锁，fn panic时同样释放锁并停止续期，panic继续向上传递
+	panicked := true
+	defer func() {
+		unlockErr := c.Unlock(context.WithoutCancel(ctx))
+		<-watched
+
+		if panicked {
+			return
+		}
+
+		var lost *LockLostError
+		if errors.As(context.Cause(fnCtx), &lost) {
+			err = lost
+			return
+		}
+
+		// 释放时锁已不再持有，说明锁在执行期间已过期
+		if errors.Is(unlockErr, ErrorLockNotHeld) {
+			err = &LockLostError{Key: key, Err: unlockErr}
+			return
+		}
+
+		if err == nil {
+			err = unlockErr
+		}
+	}()
+
+	err = fn(fnCtx)
+	panicked = false
+
+	return
+}
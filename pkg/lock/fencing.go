@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fencingKeySuffix = ":fencing" // fencing token计数器后缀，计数器与锁位于同一个slot，不设置过期时间
+
+	// fencingLockScript 获取互斥锁成功时递增fencing token计数器并返回token，锁被占用时返回0
+	fencingLockScript = `
+if redis.call('set', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('incr', KEYS[2])
+end
+return 0
+`
+)
+
+var (
+	ErrorFencingRedlock = errors.New("lock fencing token is not supported with multiple redis nodes")
+)
+
+// Fencing 配置互斥锁获取成功时是否生成fencing token（见 Core.Token），默认关闭。
+// 开启后每个key额外保留一个不过期的计数器（key + ":fencing"），仅适用于数量有限的业务key；
+// 仅支持单节点（RedisClient），与 RedisClients 同时配置时 New 返回 ErrorFencingRedlock
+func Fencing(b bool) Option {
+	return func(l *Lock) {
+		l.Fencing = b
+	}
+}
+
+// Token 返回获取互斥锁时生成的fencing token，同一个key每次获取成功的token严格递增，未开启 Fencing 时为0。
+// 持锁方写入下游存储时携带token，下游拒绝token小于已写入token的请求，
+// 避免锁过期后（如GC停顿）原持锁方的写入覆盖新持锁方的写入
+func (c *Core) Token() int64 {
+	return c.token
+}
+
+// acquireFencing 在单个节点上获取互斥锁，获取成功时在同一脚本中生成fencing token
+func (c *Core) acquireFencing(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	token, err := client.Eval(ctx, fencingLockScript, []string{c.key, fencingKey(c.key)}, c.value, c.TTL.Milliseconds()).Int64()
+	if err != nil || token == 0 {
+		return false, err
+	}
+
+	c.token = token
+	return true, nil
+}
+
+// fencingKey 返回fencing token计数器的键，Redis Cluster下与锁键位于同一个slot
+func fencingKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key + fencingKeySuffix
+		}
+	}
+	return "{" + key + "}" + fencingKeySuffix
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mel0dys0ng/song/pkg/safe"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -17,6 +19,16 @@ const (
 	_TimeoutDefault  = 1 * time.Second
 	renewMinInterval = 200 * time.Millisecond
 	renewMaxInterval = 5 * time.Second
+	clockDriftFactor = 0.01                 // Redlock时钟漂移系数，漂移时间为 TTL * 系数 + clockDriftMin
+	clockDriftMin    = 2 * time.Millisecond // Redlock最小时钟漂移
+)
+
+const (
+	modeExclusive lockMode = iota // 互斥锁
+	modeReentrant                 // 可重入锁
+	modeRead                      // 读写锁的读锁
+	modeWrite                     // 读写锁的写锁
+	modeSemaphore                 // 计数信号量
 )
 
 var (
@@ -38,8 +50,13 @@ type (
 	Core struct {
 		C           chan error              // 锁续期失败通知通道
 		key         string                  // 业务key
-		value       string                  // uuid，解决错误删除锁的问题
+		value       string                  // uuid，解决错误删除锁的问题；可重入锁为持有者标识
+		mode        lockMode                // 锁类型
 		renewCancel context.CancelCauseFunc // 续期协程取消函数
+		token       int64                   // fencing token
+		limit       int64                   // 信号量最大持有者数量
+		writerWait  time.Duration           // 获取写锁失败时设置的写锁等待标记有效期
+		writerID    string                  // 写锁等待标记的写锁标识，为空时使用共享的等待标记
 		once        sync.Once               // 锁对象只允许被释放一次
 		mu          sync.Mutex              // 保护 Core 状态
 		options
@@ -47,10 +64,16 @@ type (
 
 	Option func(l *Lock)
 
+	// lockMode 锁类型，决定获取、续期和释放锁使用的Redis命令
+	lockMode int
+
 	options struct {
-		redisClient redis.UniversalClient
-		TTL         time.Duration // 锁超时时间
-		Timeout     time.Duration // 锁等待时间
+		redisClient  redis.UniversalClient
+		redisClients []redis.UniversalClient // Redlock使用的相互独立的Redis节点
+		TTL          time.Duration           // 锁超时时间
+		Timeout      time.Duration           // 锁等待时间
+		Notify       bool                    // 释放锁时发布通知，LockWait 订阅通知后立即重试
+		Fencing      bool                    // 互斥锁获取成功时生成fencing token
 	}
 )
 
@@ -61,6 +84,15 @@ func RedisClient(client redis.UniversalClient) Option {
 	}
 }
 
+// RedisClients 配置多个相互独立的Redis节点（非同一集群的主从或分片），使用Redlock算法：
+// 在多数节点上获取成功且扣除耗时和时钟漂移后仍在有效期内才算获取成功，续期需多数节点成功，释放时释放所有节点。
+// 配置后忽略 RedisClient，Core 的使用方式不变
+func RedisClients(clients ...redis.UniversalClient) Option {
+	return func(l *Lock) {
+		l.redisClients = clients
+	}
+}
+
 // TTL 配置锁超时时间，默认10秒。时间
 func TTL(t time.Duration) Option {
 	return func(l *Lock) {
@@ -75,6 +107,14 @@ func Timeout(t time.Duration) Option {
 	}
 }
 
+// Notify 配置释放锁时是否通过Redis pub/sub（频道为 key + ":unlock"）发布通知，默认关闭。
+// 开启后 LockWait 订阅该频道，锁被释放后立即重试；持锁方和等待方需使用相同配置
+func Notify(b bool) Option {
+	return func(l *Lock) {
+		l.Notify = b
+	}
+}
+
 /*
 New 创建并初始化一个分布式锁实例。
 
@@ -103,11 +143,24 @@ func New(opts ...Option) (lock *Lock, err error) {
 		opt(lock)
 	}
 
-	if lock.redisClient == nil {
+	if lock.redisClient == nil && len(lock.redisClients) == 0 {
 		err = ErrorRedisClientNil
 		return
 	}
 
+	for _, client := range lock.redisClients {
+		if client == nil {
+			err = ErrorRedisClientNil
+			return
+		}
+	}
+
+	// Redlock多数节点计数器的最大值不保证严格递增，fencing token仅支持单节点
+	if lock.Fencing && len(lock.redisClients) > 1 {
+		err = ErrorFencingRedlock
+		return
+	}
+
 	if lock.TTL <= 0 {
 		lock.TTL = _TTLDefault
 	}
@@ -177,16 +230,26 @@ func (c *Core) lock(ctx context.Context) (ok bool, err error) {
 	timeoutCtx, cancel := context.WithTimeoutCause(ctx, c.Timeout, ErrorLockTimeout)
 	defer cancel()
 
-	ok, err = c.redisClient.SetNX(timeoutCtx, c.key, c.value, c.TTL).Result()
-	if err != nil {
-		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
-			err = fmt.Errorf("%w: %v", context.Cause(timeoutCtx), err)
-		}
-		return
-	}
+	start := time.Now()
+	n, err := c.each(timeoutCtx, c.acquireOn)
 
-	// 锁已被占用
+	// 锁的有效时间需扣除获取耗时和时钟漂移，多数节点获取成功且仍有有效时间才算获取成功
+	validity := c.TTL - time.Since(start) - c.clockDrift()
+	ok = n >= c.quorum() && validity > 0
 	if !ok {
+		// 释放所有节点，包括获取成功但未达到多数的节点以及响应丢失的节点；
+		// 可重入锁在节点出错时无法区分本次是否计数，不做释放以免扣减外层的持有计数
+		if n > 0 || (err != nil && c.mode != modeReentrant) {
+			c.releaseAll(ctx)
+		}
+
+		if n < c.quorum() && err != nil {
+			if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%w: %v", context.Cause(timeoutCtx), err)
+			}
+			return
+		}
+
 		err = ErrorLockAcquiredByOther
 		return
 	}
@@ -203,6 +266,133 @@ func (c *Core) lock(ctx context.Context) (ok bool, err error) {
 	return
 }
 
+// nodes 返回锁所在的Redis节点
+func (o *options) nodes() []redis.UniversalClient {
+	if len(o.redisClients) > 0 {
+		return o.redisClients
+	}
+	return []redis.UniversalClient{o.redisClient}
+}
+
+// quorum 返回获取和续期锁需要成功的节点数，为节点数的多数
+func (c *Core) quorum() int {
+	return len(c.nodes())/2 + 1
+}
+
+// clockDrift 返回节点间的时钟漂移，单节点时为0
+func (c *Core) clockDrift() time.Duration {
+	if len(c.nodes()) <= 1 {
+		return 0
+	}
+	return time.Duration(float64(c.TTL)*clockDriftFactor) + clockDriftMin
+}
+
+// each 在所有节点上并发执行操作，返回执行成功的节点数以及合并的错误
+func (c *Core) each(ctx context.Context, fn func(ctx context.Context, client redis.UniversalClient) (bool, error)) (n int, err error) {
+	nodes := c.nodes()
+	if len(nodes) == 1 {
+		ok, err := fn(ctx, nodes[0])
+		if ok {
+			n = 1
+		}
+		return n, err
+	}
+
+	var count atomic.Int64
+	wg := safe.NewWaitGroup()
+	for _, node := range nodes {
+		wg.Go(ctx, func(ctx context.Context) error {
+			ok, err := fn(ctx, node)
+			if ok {
+				count.Add(1)
+			}
+			return err
+		})
+	}
+
+	err = wg.Wait()
+	return int(count.Load()), err
+}
+
+// acquireOn 在单个节点上获取锁
+func (c *Core) acquireOn(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	switch c.mode {
+	case modeReentrant:
+		return c.acquireReentrant(ctx, client)
+	case modeRead, modeWrite:
+		return c.acquireRW(ctx, client)
+	case modeSemaphore:
+		return c.acquireSemaphore(ctx, client)
+	}
+	if c.Fencing {
+		return c.acquireFencing(ctx, client)
+	}
+	return client.SetNX(ctx, c.key, c.value, c.TTL).Result()
+}
+
+// renewOn 在单个节点上续期锁
+func (c *Core) renewOn(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	switch c.mode {
+	case modeReentrant:
+		return c.renewReentrant(ctx, client)
+	case modeRead, modeWrite:
+		return c.renewRW(ctx, client)
+	case modeSemaphore:
+		return c.renewSemaphore(ctx, client)
+	}
+
+	script := `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('pexpire', KEYS[1], ARGV[2])
+else
+	return 0
+end
+`
+
+	res, err := client.Eval(ctx, script, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
+	return res == 1, err
+}
+
+// releaseOn 在单个节点上释放锁，返回锁是否由当前持有者持有
+func (c *Core) releaseOn(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	notify := "0"
+	if c.Notify {
+		notify = "1"
+	}
+
+	switch c.mode {
+	case modeReentrant:
+		return c.releaseReentrant(ctx, client, notify)
+	case modeRead, modeWrite:
+		return c.releaseRW(ctx, client, notify)
+	case modeSemaphore:
+		return c.releaseSemaphore(ctx, client, notify)
+	}
+
+	script := `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	local res = redis.call('del', KEYS[1])
+	if ARGV[2] == '1' then
+		redis.call('publish', KEYS[1] .. ARGV[3], '1')
+	end
+	return res
+else
+	return 0
+end
+`
+
+	res, err := client.Eval(ctx, script, []string{c.key}, c.value, notify, unlockChannelSuffix).Int64()
+	return res == 1, err
+}
+
+// releaseAll 获取锁失败时尽力释放所有节点上的锁，不受获取超时影响
+func (c *Core) releaseAll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.Timeout)
+	defer cancel()
+
+	_, _ = c.each(ctx, c.releaseOn)
+}
+
 func (c *Core) renew(ctx context.Context) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -295,47 +485,34 @@ func (c *Core) Unlock(ctx context.Context) (err error) {
 			c.renewCancel(ErrorLockRenewCanceledUnlock)
 		}
 
-		script := `
-if redis.call('get', KEYS[1]) == ARGV[1] then
-	return redis.call('del', KEYS[1])
-else
-	return 0
-end
-`
-
-		res, evalErr := c.redisClient.Eval(ctx, script, []string{c.key}, c.value).Int64()
-		if evalErr != nil {
-			unlockErr = errors.Join(ErrorUnlockFailed, evalErr)
-		} else if res != 1 {
-			unlockErr = ErrorLockNotHeld
+		// 在所有节点上释放锁，任一节点释放成功即视为成功
+		n, evalErr := c.each(ctx, c.releaseOn)
+		if n == 0 {
+			if evalErr != nil {
+				unlockErr = errors.Join(ErrorUnlockFailed, evalErr)
+			} else {
+				unlockErr = ErrorLockNotHeld
+			}
 		}
 	})
 
 	return unlockErr
 }
 
-// 锁续期
+// 锁续期，多数节点续期成功才算成功
 func (c *Core) renewTTL(ctx context.Context) (err error) {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	script := `
-if redis.call('get', KEYS[1]) == ARGV[1] then
-	return redis.call('pexpire', KEYS[1], ARGV[2])
-else
-	return 0
-end
-`
+	n, err := c.each(ctx, c.renewOn)
+	if n >= c.quorum() {
+		return nil
+	}
 
-	res, err := c.redisClient.Eval(ctx, script, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
 	if err != nil {
 		return err // 返回原始错误
 	}
 
-	if res != 1 {
-		err = ErrorLockNotHeld
-	}
-
-	return
+	return ErrorLockNotHeld
 }
@@ -0,0 +1,436 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLock(t *testing.T, opts ...Option) *Lock {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	l, err := New(append([]Option{RedisClient(client)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestLockWait(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t, TTL(time.Second), Notify(true))
+
+	holder, ok, err := l.Lock(ctx, "wait")
+	if !ok || err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	// 持锁期间等待超时
+	if _, ok, err = l.LockWait(ctx, "wait", WaitTimeout(50*time.Millisecond)); ok || !errors.Is(err, ErrorLockWaitTimeout) {
+		t.Fatalf("LockWait: %v, want %v", err, ErrorLockWaitTimeout)
+	}
+
+	// 退避间隔远大于释放时间，释放通知使等待方立即重试
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = holder.Unlock(ctx)
+	}()
+
+	start := time.Now()
+	c, ok, err := l.LockWait(ctx, "wait", WaitBackoff(5*time.Second, 5*time.Second), WaitTimeout(10*time.Second))
+	if !ok || err != nil {
+		t.Fatalf("LockWait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("LockWait took %v, unlock notification not received", elapsed)
+	}
+	if err = c.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	holder, _, _ = l.Lock(ctx, "wait")
+	defer func() {
+		_ = holder.Unlock(ctx)
+	}()
+	if _, _, err = l.LockWait(cancelCtx, "wait"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("LockWait with canceled ctx: %v", err)
+	}
+}
+
+func TestLockReentrant(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t, TTL(2*time.Second))
+
+	if _, _, err := l.LockReentrant(ctx, "reentrant"); !errors.Is(err, ErrorOwnerEmpty) {
+		t.Fatalf("LockReentrant without owner: %v", err)
+	}
+
+	ownerCtx := WithOwner(ctx)
+	if WithOwner(ownerCtx) != ownerCtx {
+		t.Fatal("WithOwner replaced existing owner")
+	}
+
+	outer, ok, err := l.LockReentrant(ownerCtx, "reentrant")
+	if !ok || err != nil {
+		t.Fatalf("LockReentrant: %v", err)
+	}
+	inner, ok, err := l.LockReentrant(ownerCtx, "reentrant")
+	if !ok || err != nil {
+		t.Fatalf("nested LockReentrant: %v", err)
+	}
+
+	// 其他持有者无法获取
+	if _, ok, err = l.LockReentrant(WithOwner(ctx), "reentrant"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("LockReentrant by other owner: %v", err)
+	}
+	if _, ok, err = l.Lock(ctx, "reentrant"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("Lock on reentrant key: %v", err)
+	}
+
+	// 内层释放后锁仍被持有，外层释放后锁被删除
+	if err = inner.Unlock(ctx); err != nil {
+		t.Fatalf("inner Unlock: %v", err)
+	}
+	if n, _ := l.redisClient.Exists(ctx, "reentrant").Result(); n != 1 {
+		t.Fatal("lock released before hold count reached zero")
+	}
+	if err = outer.Unlock(ctx); err != nil {
+		t.Fatalf("outer Unlock: %v", err)
+	}
+	if n, _ := l.redisClient.Exists(ctx, "reentrant").Result(); n != 0 {
+		t.Fatal("lock not released after hold count reached zero")
+	}
+
+	// 普通锁占用时可重入锁获取失败
+	plain, _, _ := l.Lock(ctx, "plain")
+	defer func() {
+		_ = plain.Unlock(ctx)
+	}()
+	if _, ok, err = l.LockReentrant(ownerCtx, "plain"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("LockReentrant on plain key: %v", err)
+	}
+}
+
+func TestRedlock(t *testing.T) {
+	ctx := context.Background()
+
+	var clients []redis.UniversalClient
+	for range 3 {
+		client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		clients = append(clients, client)
+	}
+
+	l, err := New(RedisClients(clients...), TTL(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 少数节点被占用时仍可获取，释放时释放所有节点
+	clients[0].Set(ctx, "redlock", "other", time.Minute)
+	c, ok, err := l.Lock(ctx, "redlock")
+	if !ok || err != nil {
+		t.Fatalf("Lock with minority held: %v", err)
+	}
+	if err = c.renewTTL(ctx); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if err = c.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	for i, client := range clients {
+		if v := client.Get(ctx, "redlock").Val(); (i == 0) != (v == "other") {
+			t.Fatalf("node %d value = %q after unlock", i, v)
+		}
+	}
+
+	// 多数节点被占用时获取失败，并释放已获取的节点
+	clients[1].Set(ctx, "redlock", "other", time.Minute)
+	if _, ok, err = l.Lock(ctx, "redlock"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("Lock with majority held: %v", err)
+	}
+	if n := clients[2].Exists(ctx, "redlock").Val(); n != 0 {
+		t.Fatal("minority lock not released after failed acquire")
+	}
+}
+
+func TestRWLock(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t, TTL(2*time.Second))
+	rw, err := NewRWLock(RedisClient(l.redisClient), TTL(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r1, ok, err := rw.RLock(ctx, "rw")
+	if !ok || err != nil {
+		t.Fatalf("RLock: %v", err)
+	}
+	r2, ok, err := rw.RLock(ctx, "rw")
+	if !ok || err != nil {
+		t.Fatalf("second RLock: %v", err)
+	}
+
+	// 读锁持有时写锁获取失败，并阻止新的读锁
+	if _, ok, err = rw.Lock(ctx, "rw"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("Lock while read held: %v", err)
+	}
+	if _, ok, err = rw.RLock(ctx, "rw"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("RLock while writer waiting: %v", err)
+	}
+	if err = r1.renewTTL(ctx); err != nil {
+		t.Fatalf("renew read lock: %v", err)
+	}
+
+	_ = r1.Unlock(ctx)
+	_ = r2.Unlock(ctx)
+
+	w, ok, err := rw.Lock(ctx, "rw")
+	if !ok || err != nil {
+		t.Fatalf("Lock after readers released: %v", err)
+	}
+	if _, ok, err = rw.RLock(ctx, "rw"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("RLock while write held: %v", err)
+	}
+	if err = w.Unlock(ctx); err != nil {
+		t.Fatalf("write Unlock: %v", err)
+	}
+
+	r3, ok, err := rw.RLock(ctx, "rw")
+	if !ok || err != nil {
+		t.Fatalf("RLock after write released: %v", err)
+	}
+	_ = r3.Unlock(ctx)
+}
+
+func TestRWLockWriterWait(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t)
+	rw, err := NewRWLock(RedisClient(l.redisClient), TTL(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 最后一个读锁释放时保留写锁等待标记，新的读锁不能抢在等待的写锁之前
+	r, _, _ := rw.RLock(ctx, "rw")
+	for _, writer := range []string{"w1", "w2"} {
+		if _, ok, _ := rw.lock(ctx, "rw", modeWrite, rwWriterWaitTTL, writer); ok {
+			t.Fatalf("%s Lock succeeded while read held", writer)
+		}
+	}
+	_ = r.Unlock(ctx)
+	if _, ok, err := rw.RLock(ctx, "rw"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("RLock after readers released while writer waiting: %v", err)
+	}
+
+	// 获取写锁时只清除自身的等待标记，其他写锁仍在等待时读锁获取失败
+	w, ok, err := rw.lock(ctx, "rw", modeWrite, rwWriterWaitTTL, "w1")
+	if !ok || err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	_ = w.Unlock(ctx)
+	if _, ok, err = rw.RLock(ctx, "rw"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("RLock while another writer waiting: %v", err)
+	}
+
+	w, ok, err = rw.lock(ctx, "rw", modeWrite, rwWriterWaitTTL, "w2")
+	if !ok || err != nil {
+		t.Fatalf("second writer Lock: %v", err)
+	}
+	_ = w.Unlock(ctx)
+
+	// 所有等待的写锁获取后，释放时读锁可立即获取
+	r, ok, err = rw.RLock(ctx, "rw")
+	if !ok || err != nil {
+		t.Fatalf("RLock after write released: %v", err)
+	}
+
+	// 等待标记有效期覆盖最大重试间隔
+	done := make(chan error, 1)
+	go func() {
+		c, _, err := rw.LockWait(ctx, "rw", WaitBackoff(time.Second, time.Second), WaitTimeout(5*time.Second))
+		if err == nil {
+			err = c.Unlock(ctx)
+		}
+		done <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	fields, err := l.redisClient.HGetAll(ctx, "rw").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wwait int64
+	for field, v := range fields {
+		if strings.HasPrefix(field, "wwait:") {
+			wwait, _ = strconv.ParseInt(v, 10, 64)
+		}
+	}
+	if left := time.Until(time.UnixMilli(wwait)); left < 1500*time.Millisecond {
+		t.Fatalf("writer wait marker expires in %v, want at least twice the backoff", left)
+	}
+
+	_ = r.Unlock(ctx)
+	if err = <-done; err != nil {
+		t.Fatalf("LockWait: %v", err)
+	}
+}
+
+func TestLockToken(t *testing.T) {
+	ctx := context.Background()
+
+	// 未开启 Fencing 时不生成token，不创建计数器
+	plain := newTestLock(t)
+	c, ok, err := plain.Lock(ctx, "token")
+	if !ok || err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if c.Token() != 0 {
+		t.Fatalf("token = %d without fencing, want 0", c.Token())
+	}
+	if n, _ := plain.redisClient.Exists(ctx, fencingKey("token")).Result(); n != 0 {
+		t.Fatal("fencing counter created without fencing")
+	}
+	_ = c.Unlock(ctx)
+
+	if _, err = New(RedisClients(plain.redisClient, plain.redisClient), Fencing(true)); !errors.Is(err, ErrorFencingRedlock) {
+		t.Fatalf("New: %v, want %v", err, ErrorFencingRedlock)
+	}
+
+	// 计数器与锁键位于同一个slot
+	if got := fencingKey("order:1"); got != "{order:1}:fencing" {
+		t.Fatalf("fencingKey = %s", got)
+	}
+	if got := fencingKey("order:{1}"); got != "order:{1}:fencing" {
+		t.Fatalf("fencingKey = %s", got)
+	}
+
+	l := newTestLock(t, Fencing(true))
+
+	// 锁被占用时不递增计数器
+	holder, _, _ := l.Lock(ctx, "token")
+	if _, ok, _ = l.Lock(ctx, "token"); ok {
+		t.Fatal("Lock succeeded while held")
+	}
+	if holder.Token() != 1 {
+		t.Fatalf("token = %d, want 1", holder.Token())
+	}
+	_ = holder.Unlock(ctx)
+
+	last := holder.Token()
+	for range 3 {
+		c, ok, err := l.Lock(ctx, "token")
+		if !ok || err != nil {
+			t.Fatalf("Lock: %v", err)
+		}
+		if c.Token() <= last {
+			t.Fatalf("token = %d, want > %d", c.Token(), last)
+		}
+		last = c.Token()
+		_ = c.Unlock(ctx)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t)
+	s, err := NewSemaphore(2, RedisClient(l.redisClient), Notify(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p1, ok, err := s.TryAcquire(ctx, "sem")
+	if !ok || err != nil {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+	p2, ok, err := s.TryAcquire(ctx, "sem")
+	if !ok || err != nil {
+		t.Fatalf("second TryAcquire: %v", err)
+	}
+	if _, ok, err = s.TryAcquire(ctx, "sem"); ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+		t.Fatalf("TryAcquire over limit: %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = s.Release(ctx, p1)
+	}()
+	p3, ok, err := s.Acquire(ctx, "sem", WaitTimeout(time.Second))
+	if !ok || err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	_ = s.Release(ctx, p2)
+	_ = s.Release(ctx, p3)
+
+	// 过期的持有者在获取时被回收
+	l.redisClient.ZAdd(ctx, "sem", redis.Z{Score: 1, Member: "expired-1"}, redis.Z{Score: 1, Member: "expired-2"})
+	p4, ok, err := s.TryAcquire(ctx, "sem")
+	if !ok || err != nil {
+		t.Fatalf("TryAcquire with expired holders: %v", err)
+	}
+	if n := l.redisClient.ZCard(ctx, "sem").Val(); n != 1 {
+		t.Fatalf("holders = %d, want 1", n)
+	}
+	_ = s.Release(ctx, p4)
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLock(t, TTL(1500*time.Millisecond))
+
+	fnErr := errors.New("fn failed")
+	if err := Do(ctx, l, "do", func(ctx context.Context) error { return fnErr }); err != fnErr {
+		t.Fatalf("Do = %v, want %v", err, fnErr)
+	}
+
+	// 执行期间锁被其他持有者获取，续期失败后取消fn的ctx
+	err := Do(ctx, l, "do", func(ctx context.Context) error {
+		l.redisClient.Set(ctx, "do", "other", time.Minute)
+		select {
+		case <-ctx.Done():
+		case <-time.After(3 * time.Second):
+			t.Error("fn ctx not canceled after lock lost")
+		}
+
+		var lost *LockLostError
+		if !errors.As(context.Cause(ctx), &lost) {
+			t.Errorf("ctx cause = %v", context.Cause(ctx))
+		}
+		return ctx.Err()
+	})
+
+	var lost *LockLostError
+	if !errors.As(err, &lost) || !errors.Is(err, ErrorLockLost) || !errors.Is(err, ErrorLockNotHeld) {
+		t.Fatalf("Do = %v, want LockLostError", err)
+	}
+
+	// fn panic时释放锁，panic继续向上传递
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recover = %v, want boom", r)
+			}
+		}()
+		_ = Do(ctx, l, "panic", func(ctx context.Context) error { panic("boom") })
+	}()
+
+	if n, _ := l.redisClient.Exists(ctx, "panic").Result(); n != 0 {
+		t.Fatal("lock still held after fn panicked")
+	}
+}
@@ -0,0 +1,125 @@
+package lock
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrorOwnerEmpty = errors.New("lock owner is empty, use WithOwner to set owner in context")
+)
+
+const (
+	// reentrantLockScript 锁不存在或由同一持有者持有时计数加1并重置过期时间，返回持有计数；被其他持有者占用返回0
+	reentrantLockScript = `
+if redis.call('exists', KEYS[1]) == 0 or (redis.call('type', KEYS[1]).ok == 'hash' and redis.call('hexists', KEYS[1], ARGV[1]) == 1) then
+	local n = redis.call('hincrby', KEYS[1], ARGV[1], 1)
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return n
+end
+return 0
+`
+
+	// reentrantRenewScript 持有者仍持有锁时重置过期时间
+	reentrantRenewScript = `
+if redis.call('type', KEYS[1]).ok == 'hash' and redis.call('hexists', KEYS[1], ARGV[1]) == 1 then
+	return redis.call('pexpire', KEYS[1], ARGV[2])
+end
+return 0
+`
+
+	// reentrantUnlockScript 持有计数减1，计数归零时删除锁并按需发布释放通知；返回剩余计数，未持有锁返回-1
+	reentrantUnlockScript = `
+if redis.call('type', KEYS[1]).ok ~= 'hash' or redis.call('hexists', KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+local n = redis.call('hincrby', KEYS[1], ARGV[1], -1)
+if n > 0 then
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return n
+end
+redis.call('del', KEYS[1])
+if ARGV[3] == '1' then
+	redis.call('publish', KEYS[1] .. ARGV[4], '1')
+end
+return 0
+`
+)
+
+type ownerKey struct{}
+
+// WithOwner 在ctx中设置可重入锁的持有者标识，ctx中已存在持有者时原样返回，
+// 保证同一逻辑调用链上的嵌套调用使用同一持有者
+func WithOwner(ctx context.Context) context.Context {
+	if _, ok := OwnerFromContext(ctx); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ownerKey{}, uuid.New().String())
+}
+
+// OwnerFromContext 获取ctx中可重入锁的持有者标识
+func OwnerFromContext(ctx context.Context) (owner string, ok bool) {
+	owner, ok = ctx.Value(ownerKey{}).(string)
+	return owner, ok && len(owner) > 0
+}
+
+// LockReentrant 尝试获取可重入分布式锁，持有者标识从ctx中获取（通过 WithOwner 设置）。
+// 同一持有者可重复获取同一个锁，每次获取的持有计数加1，每个返回的 Core 需各自调用 Unlock，
+// 计数归零时才真正释放锁。锁续期与 Lock 一致，每个 Core 在释放前各自续期。
+//
+// 参数:
+//
+//	ctx: 上下文，需携带持有者标识，同时用于控制请求的生命周期（如超时或取消）。
+//	key: 要锁定的资源唯一标识符，不可为空。
+//
+// 返回值:
+//
+//	c: 成功时返回锁的核心控制对象；失败时为nil。
+//	ok: 是否成功获取到锁。
+//	err: ctx未携带持有者返回 ErrorOwnerEmpty，锁被其他持有者占用返回 ErrorLockAcquiredByOther，以及其他错误。
+func (l *Lock) LockReentrant(ctx context.Context, key string) (c *Core, ok bool, err error) {
+	if len(key) == 0 {
+		err = ErrorKeyEmpty
+		return
+	}
+
+	owner, exists := OwnerFromContext(ctx)
+	if !exists {
+		err = ErrorOwnerEmpty
+		return
+	}
+
+	c = &Core{
+		key:     key,
+		value:   owner,
+		mode:    modeReentrant,
+		options: l.options,
+		C:       make(chan error, 2),
+	}
+
+	ok, err = c.lock(ctx)
+
+	return
+}
+
+// acquireReentrant 在单个节点上获取可重入锁
+func (c *Core) acquireReentrant(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	n, err := client.Eval(ctx, reentrantLockScript, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
+	return n > 0, err
+}
+
+// renewReentrant 在单个节点上续期可重入锁
+func (c *Core) renewReentrant(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	res, err := client.Eval(ctx, reentrantRenewScript, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
+	return res == 1, err
+}
+
+// releaseReentrant 在单个节点上释放一次可重入锁的持有，返回锁是否由当前持有者持有
+func (c *Core) releaseReentrant(ctx context.Context, client redis.UniversalClient, notify string) (bool, error) {
+	res, err := client.Eval(ctx, reentrantUnlockScript, []string{c.key},
+		c.value, c.TTL.Milliseconds(), notify, unlockChannelSuffix).Int64()
+	return res >= 0, err
+}
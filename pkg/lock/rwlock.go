@@ -0,0 +1,232 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	rwWriterWaitTTL = time.Second // 写锁等待标记的默认有效期，等待期间新的读锁获取失败
+)
+
+const (
+	// rwPrelude 读写锁脚本公共部分：清理已过期的持有者和写锁等待标记，无持有者时清除锁（保留未过期的写锁等待标记），
+	// 得到当前时间、持有者数量、等待的写锁数量和锁模式。
+	// 锁为Hash结构，mode字段为锁模式（read/write），wwait 及 wwait:写锁标识 字段为写锁等待标记的截止时间，其余字段为持有者标识及其截止时间
+	rwPrelude = `
+local t = redis.call('time')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+local holders = 0
+local waiting = 0
+local kv = redis.call('hgetall', KEYS[1])
+for i = 1, #kv, 2 do
+	if kv[i] ~= 'mode' then
+		if tonumber(kv[i + 1]) <= now then
+			redis.call('hdel', KEYS[1], kv[i])
+		elseif string.sub(kv[i], 1, 5) == 'wwait' then
+			waiting = waiting + 1
+		else
+			holders = holders + 1
+		end
+	end
+end
+local function clear()
+	if waiting > 0 then
+		redis.call('hdel', KEYS[1], 'mode')
+	else
+		redis.call('del', KEYS[1])
+	end
+end
+if holders == 0 then
+	clear()
+end
+local mode = redis.call('hget', KEYS[1], 'mode')
+local function hold()
+	redis.call('hset', KEYS[1], ARGV[1], now + ttl)
+	if redis.call('pttl', KEYS[1]) < ttl then
+		redis.call('pexpire', KEYS[1], ttl)
+	end
+end
+`
+
+	// rwReadLockScript 无写锁且无等待的写锁时获取读锁，成功返回1
+	rwReadLockScript = rwPrelude + `
+if mode == 'write' or waiting > 0 then
+	return 0
+end
+redis.call('hset', KEYS[1], 'mode', 'read')
+hold()
+return 1
+`
+
+	// rwWriteLockScript 无持有者时获取写锁并清除自身和共享的写锁等待标记，其他写锁的等待标记保留，成功返回1；
+	// 否则设置写锁等待标记，阻止新的读锁获取。ARGV[4]为写锁标识，为空时使用共享的等待标记
+	rwWriteLockScript = rwPrelude + `
+local wwait = 'wwait'
+if ARGV[4] ~= '' then
+	wwait = 'wwait:' .. ARGV[4]
+end
+if holders == 0 then
+	redis.call('hdel', KEYS[1], 'wwait', wwait)
+	redis.call('hset', KEYS[1], 'mode', 'write')
+	hold()
+	return 1
+end
+local wait = tonumber(ARGV[3])
+redis.call('hset', KEYS[1], wwait, now + wait)
+if redis.call('pttl', KEYS[1]) < wait then
+	redis.call('pexpire', KEYS[1], wait)
+end
+return 0
+`
+
+	// rwRenewScript 持有者仍持有锁时延长其截止时间，成功返回1
+	rwRenewScript = rwPrelude + `
+if redis.call('hexists', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+hold()
+return 1
+`
+
+	// rwUnlockScript 释放持有者，最后一个持有者释放时清除锁并按需发布释放通知；未持有锁返回0
+	rwUnlockScript = rwPrelude + `
+if redis.call('hdel', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if holders == 1 then
+	clear()
+	if ARGV[3] == '1' then
+		redis.call('publish', KEYS[1] .. ARGV[4], '1')
+	end
+end
+return 1
+`
+)
+
+type (
+	// RWLock 分布式读写锁，允许多个读锁同时持有，写锁与其他任何锁互斥。
+	// 写锁获取失败时设置等待标记，存在未过期的等待标记时新的读锁获取失败，避免读锁持续占用导致写锁饥饿
+	RWLock struct {
+		options
+	}
+)
+
+// NewRWLock 创建分布式读写锁实例，配置项与 New 相同
+func NewRWLock(opts ...Option) (rw *RWLock, err error) {
+	l, err := New(opts...)
+	if err != nil {
+		return
+	}
+
+	return &RWLock{options: l.options}, nil
+}
+
+// RLock 尝试获取读锁，每次获取使用独立的持有者标识，返回的 Core 需各自调用 Unlock
+//
+// 参数:
+//
+//	ctx: 上下文，用于控制请求的生命周期（如超时或取消）。
+//	key: 要锁定的资源唯一标识符，不可为空，不可与互斥锁、可重入锁共用。
+//
+// 返回值:
+//
+//	c: 成功时返回锁的核心控制对象；失败时为nil。
+//	ok: 是否成功获取到读锁。
+//	err: 写锁被持有或有写锁等待时返回 ErrorLockAcquiredByOther，以及其他错误。
+func (rw *RWLock) RLock(ctx context.Context, key string) (c *Core, ok bool, err error) {
+	return rw.lock(ctx, key, modeRead, 0, "")
+}
+
+// Lock 尝试获取写锁，有持有者时获取失败并设置共享的写锁等待标记，任一写锁获取成功时清除
+//
+// 参数:
+//
+//	ctx: 上下文，用于控制请求的生命周期（如超时或取消）。
+//	key: 要锁定的资源唯一标识符，不可为空，不可与互斥锁、可重入锁共用。
+//
+// 返回值:
+//
+//	c: 成功时返回锁的核心控制对象；失败时为nil。
+//	ok: 是否成功获取到写锁。
+//	err: 读锁或写锁被持有时返回 ErrorLockAcquiredByOther，以及其他错误。
+func (rw *RWLock) Lock(ctx context.Context, key string) (c *Core, ok bool, err error) {
+	return rw.lock(ctx, key, modeWrite, rwWriterWaitTTL, "")
+}
+
+// RLockf 使用格式化字符串和参数生成锁键，并尝试获取读锁
+func (rw *RWLock) RLockf(ctx context.Context, format string, value ...any) (*Core, bool, error) {
+	return rw.RLock(ctx, fmt.Sprintf(format, value...))
+}
+
+// Lockf 使用格式化字符串和参数生成锁键，并尝试获取写锁
+func (rw *RWLock) Lockf(ctx context.Context, format string, value ...any) (*Core, bool, error) {
+	return rw.Lock(ctx, fmt.Sprintf(format, value...))
+}
+
+// RLockWait 阻塞获取读锁，重试方式与 Lock.LockWait 相同
+func (rw *RWLock) RLockWait(ctx context.Context, key string, opts ...WaitOption) (c *Core, ok bool, err error) {
+	return rw.wait(ctx, key, rw.RLock, opts...)
+}
+
+// LockWait 阻塞获取写锁，重试方式与 Lock.LockWait 相同，等待期间持续阻止新的读锁获取。
+// 等待标记按写锁标识区分，获取成功时只清除自身的等待标记，其他仍在等待的写锁继续阻止新的读锁获取。
+// 写锁等待标记的有效期不小于两倍最大重试间隔，每次重试时刷新，避免重试间隔内读锁抢占导致写锁饥饿
+func (rw *RWLock) LockWait(ctx context.Context, key string, opts ...WaitOption) (c *Core, ok bool, err error) {
+	writerID := uuid.New().String()
+	writerWait := max(rwWriterWaitTTL, 2*newWait(opts).max)
+	return rw.wait(ctx, key, func(ctx context.Context, key string) (*Core, bool, error) {
+		return rw.lock(ctx, key, modeWrite, writerWait, writerID)
+	}, opts...)
+}
+
+func (rw *RWLock) lock(ctx context.Context, key string, mode lockMode, writerWait time.Duration, writerID string) (c *Core, ok bool, err error) {
+	if len(key) == 0 {
+		err = ErrorKeyEmpty
+		return
+	}
+
+	c = &Core{
+		key:        key,
+		value:      uuid.New().String(),
+		mode:       mode,
+		writerWait: writerWait,
+		writerID:   writerID,
+		options:    rw.options,
+		C:          make(chan error, 2),
+	}
+
+	ok, err = c.lock(ctx)
+
+	return
+}
+
+// acquireRW 在单个节点上获取读锁或写锁
+func (c *Core) acquireRW(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	script := rwReadLockScript
+	if c.mode == modeWrite {
+		script = rwWriteLockScript
+	}
+
+	res, err := client.Eval(ctx, script, []string{c.key},
+		c.value, c.TTL.Milliseconds(), c.writerWait.Milliseconds(), c.writerID).Int64()
+	return res == 1, err
+}
+
+// renewRW 在单个节点上续期读锁或写锁
+func (c *Core) renewRW(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	res, err := client.Eval(ctx, rwRenewScript, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
+	return res == 1, err
+}
+
+// releaseRW 在单个节点上释放读锁或写锁，返回锁是否由当前持有者持有
+func (c *Core) releaseRW(ctx context.Context, client redis.UniversalClient, notify string) (bool, error) {
+	res, err := client.Eval(ctx, rwUnlockScript, []string{c.key},
+		c.value, c.TTL.Milliseconds(), notify, unlockChannelSuffix).Int64()
+	return res == 1, err
+}
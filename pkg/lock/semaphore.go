@@ -0,0 +1,152 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrorSemaphoreLimitInvalid = errors.New("semaphore limit must be greater than 0")
+)
+
+const (
+	// semaphorePrelude 信号量脚本公共部分：清理已过期的持有者。
+	// 信号量为有序集合，成员为持有者标识，分数为持有者的截止时间
+	semaphorePrelude = `
+local t = redis.call('time')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+redis.call('zremrangebyscore', KEYS[1], '-inf', now)
+local function hold()
+	redis.call('zadd', KEYS[1], now + ttl, ARGV[1])
+	if redis.call('pttl', KEYS[1]) < ttl then
+		redis.call('pexpire', KEYS[1], ttl)
+	end
+end
+`
+
+	// semaphoreAcquireScript 持有者数量未达到上限时加入持有者，成功返回1
+	semaphoreAcquireScript = semaphorePrelude + `
+if redis.call('zcard', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+hold()
+return 1
+`
+
+	// semaphoreRenewScript 持有者仍持有许可时延长其截止时间，成功返回1
+	semaphoreRenewScript = semaphorePrelude + `
+if not redis.call('zscore', KEYS[1], ARGV[1]) then
+	return 0
+end
+hold()
+return 1
+`
+
+	// semaphoreReleaseScript 移除持有者并按需发布释放通知；未持有许可返回0
+	semaphoreReleaseScript = `
+local res = redis.call('zrem', KEYS[1], ARGV[1])
+if res == 1 and ARGV[2] == '1' then
+	redis.call('publish', KEYS[1] .. ARGV[3], '1')
+end
+return res
+`
+)
+
+type (
+	// Semaphore 分布式计数信号量，同一个key最多同时被limit个持有者持有，用于跨实例限制并发数。
+	// 持有者的截止时间记录在有序集合中，获取和续期时清理已过期的持有者
+	Semaphore struct {
+		options
+		limit int64 // 最大持有者数量
+	}
+)
+
+// NewSemaphore 创建分布式计数信号量实例，配置项与 New 相同
+//
+// 参数:
+//
+//	limit: 最大持有者数量，需大于0。
+//	opts: 配置选项。
+func NewSemaphore(limit int64, opts ...Option) (s *Semaphore, err error) {
+	if limit <= 0 {
+		err = ErrorSemaphoreLimitInvalid
+		return
+	}
+
+	l, err := New(opts...)
+	if err != nil {
+		return
+	}
+
+	return &Semaphore{options: l.options, limit: limit}, nil
+}
+
+// TryAcquire 尝试获取一个许可，不等待。获取成功后与 Lock 一致自动续期，使用完毕需调用 Release 或 Core.Unlock
+//
+// 参数:
+//
+//	ctx: 上下文，用于控制请求的生命周期（如超时或取消）。
+//	key: 信号量的唯一标识符，不可为空，不可与其他锁共用。
+//
+// 返回值:
+//
+//	c: 成功时返回许可的核心控制对象；失败时为nil。
+//	ok: 是否成功获取到许可。
+//	err: 许可已用完返回 ErrorLockAcquiredByOther，以及其他错误。
+func (s *Semaphore) TryAcquire(ctx context.Context, key string) (c *Core, ok bool, err error) {
+	if len(key) == 0 {
+		err = ErrorKeyEmpty
+		return
+	}
+
+	c = &Core{
+		key:     key,
+		value:   uuid.New().String(),
+		mode:    modeSemaphore,
+		limit:   s.limit,
+		options: s.options,
+		C:       make(chan error, 2),
+	}
+
+	ok, err = c.lock(ctx)
+
+	return
+}
+
+// TryAcquiref 使用格式化字符串和参数生成信号量键，并尝试获取一个许可
+func (s *Semaphore) TryAcquiref(ctx context.Context, format string, value ...any) (*Core, bool, error) {
+	return s.TryAcquire(ctx, fmt.Sprintf(format, value...))
+}
+
+// Acquire 阻塞获取一个许可，重试方式与 Lock.LockWait 相同
+func (s *Semaphore) Acquire(ctx context.Context, key string, opts ...WaitOption) (c *Core, ok bool, err error) {
+	return s.wait(ctx, key, s.TryAcquire, opts...)
+}
+
+// Release 释放许可，等同于 c.Unlock
+func (s *Semaphore) Release(ctx context.Context, c *Core) error {
+	return c.Unlock(ctx)
+}
+
+// acquireSemaphore 在单个节点上获取许可
+func (c *Core) acquireSemaphore(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	res, err := client.Eval(ctx, semaphoreAcquireScript, []string{c.key}, c.value, c.TTL.Milliseconds(), c.limit).Int64()
+	return res == 1, err
+}
+
+// renewSemaphore 在单个节点上续期许可
+func (c *Core) renewSemaphore(ctx context.Context, client redis.UniversalClient) (bool, error) {
+	res, err := client.Eval(ctx, semaphoreRenewScript, []string{c.key}, c.value, c.TTL.Milliseconds()).Int64()
+	return res == 1, err
+}
+
+// releaseSemaphore 在单个节点上释放许可，返回许可是否由当前持有者持有
+func (c *Core) releaseSemaphore(ctx context.Context, client redis.UniversalClient, notify string) (bool, error) {
+	res, err := client.Eval(ctx, semaphoreReleaseScript, []string{c.key}, c.value, notify, unlockChannelSuffix).Int64()
+	return res == 1, err
+}
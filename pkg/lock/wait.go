@@ -0,0 +1,134 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	_WaitTimeoutDefault = 5 * time.Second        // 等待锁的默认时长
+	_WaitMinDefault     = 10 * time.Millisecond  // 重试获取锁的默认最小间隔
+	_WaitMaxDefault     = 500 * time.Millisecond // 重试获取锁的默认最大间隔
+	unlockChannelSuffix = ":unlock"              // 释放锁通知频道后缀，频道名为 key + 后缀
+)
+
+var (
+	ErrorLockWaitTimeout = errors.New("lock wait timeout")
+)
+
+type (
+	// WaitOption LockWait 配置
+	WaitOption func(w *wait)
+
+	wait struct {
+		timeout time.Duration // 等待锁的时长
+		min     time.Duration // 重试获取锁的最小间隔
+		max     time.Duration // 重试获取锁的最大间隔
+	}
+)
+
+// WaitTimeout 配置等待锁的时长，默认5秒
+func WaitTimeout(t time.Duration) WaitOption {
+	return func(w *wait) {
+		w.timeout = t
+	}
+}
+
+// WaitBackoff 配置重试获取锁的间隔，从min开始按2倍递增至max，每次间隔在[d/2, d]范围内随机抖动，默认10毫秒至500毫秒
+func WaitBackoff(min, max time.Duration) WaitOption {
+	return func(w *wait) {
+		w.min = min
+		w.max = max
+	}
+}
+
+// newWait 返回应用配置并补全默认值后的等待配置
+func newWait(opts []WaitOption) *wait {
+	w := &wait{
+		timeout: _WaitTimeoutDefault,
+		min:     _WaitMinDefault,
+		max:     _WaitMaxDefault,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.timeout <= 0 {
+		w.timeout = _WaitTimeoutDefault
+	}
+
+	if w.min <= 0 {
+		w.min = _WaitMinDefault
+	}
+
+	w.max = max(w.max, w.min)
+
+	return w
+}
+
+// LockWait 阻塞获取分布式锁，锁被占用时按带抖动的指数退避间隔重试，直到获取成功、等待超时或ctx取消。
+// Lock 配置 Notify 时同时订阅释放锁通知，锁被释放后立即重试，无需等待退避间隔。
+//
+// 参数:
+//
+//	ctx: 上下文，用于控制请求的生命周期（如超时或取消），获取成功后用于锁续期。
+//	key: 要锁定的资源唯一标识符，不可为空。
+//	opts: 等待配置。
+//
+// 返回值:
+//
+//	c: 成功时返回锁的核心控制对象；失败时为nil。
+//	ok: 是否成功获取到锁。
+//	err: 等待超时返回 ErrorLockWaitTimeout，ctx取消返回ctx的错误，以及获取锁过程中发生的其他错误。
+func (l *Lock) LockWait(ctx context.Context, key string, opts ...WaitOption) (c *Core, ok bool, err error) {
+	return l.wait(ctx, key, l.Lock, opts...)
+}
+
+// wait 按带抖动的指数退避间隔重试获取锁，直到获取成功、等待超时或ctx取消
+func (o *options) wait(ctx context.Context, key string, acquire func(ctx context.Context, key string) (*Core, bool, error), opts ...WaitOption) (c *Core, ok bool, err error) {
+	if len(key) == 0 {
+		err = ErrorKeyEmpty
+		return
+	}
+
+	w := newWait(opts)
+
+	// 等待超时仅用于控制等待，获取成功后锁续期使用原ctx
+	waitCtx, cancel := context.WithTimeoutCause(ctx, w.timeout, ErrorLockWaitTimeout)
+	defer cancel()
+
+	var notify <-chan *redis.Message
+	if o.Notify {
+		pubsub := o.nodes()[0].Subscribe(waitCtx, key+unlockChannelSuffix)
+		defer func() {
+			_ = pubsub.Close()
+		}()
+
+		// 订阅失败时仅按退避间隔重试
+		if _, er := pubsub.Receive(waitCtx); er == nil {
+			notify = pubsub.Channel()
+		}
+	}
+
+	for d := w.min; ; d = min(d*2, w.max) {
+		c, ok, err = acquire(ctx, key)
+		if ok || !errors.Is(err, ErrorLockAcquiredByOther) {
+			return
+		}
+
+		timer := time.NewTimer(d/2 + rand.N(d/2+1))
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+			return nil, false, context.Cause(waitCtx)
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/mel0dys0ng/song/pkg/cache"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	PrimaryKeyDefault = "id" // 默认主键字段
+)
+
+type (
+	// CachedRepository 带缓存的泛型仓库，主键和唯一键查询通过缓存读取，写操作后自动删除受影响数据的缓存。
+	// 嵌入的Repository方法（Query、QueryList、QueryCount）不经过缓存。
+	// 写操作使用事务（Tx）时，缓存在事务提交成功后才删除（见 AfterCommit），回滚时不删除
+	CachedRepository[T ModelInterface] struct {
+		*Repository[T]
+		cache      *cache.Cache[T]
+		name       string           // 缓存名称，默认为表名
+		primaryKey string           // 主键字段，默认为id
+		id         func(data T) any // 获取数据的主键值，需与Cache的DataId一致
+		uniqueKeys []uniqueKey[T]   // 唯一键
+	}
+
+	// CachedRepositoryOption 带缓存的泛型仓库配置
+	CachedRepositoryOption[T ModelInterface] func(r *CachedRepository[T])
+
+	uniqueKey[T ModelInterface] struct {
+		column string
+		value  func(data T) any
+	}
+)
+
+// CachedOptionName 配置缓存名称，默认为表名
+func CachedOptionName[T ModelInterface](name string) CachedRepositoryOption[T] {
+	return func(r *CachedRepository[T]) {
+		r.name = name
+	}
+}
+
+// CachedOptionPrimaryKey 配置主键字段，默认为id
+func CachedOptionPrimaryKey[T ModelInterface](column string) CachedRepositoryOption[T] {
+	return func(r *CachedRepository[T]) {
+		r.primaryKey = column
+	}
+}
+
+// CachedOptionUniqueKey 配置唯一键字段及其取值函数，配置后可通过 QueryByUniqueKey 查询
+func CachedOptionUniqueKey[T ModelInterface](column string, value func(data T) any) CachedRepositoryOption[T] {
+	return func(r *CachedRepository[T]) {
+		r.uniqueKeys = append(r.uniqueKeys, uniqueKey[T]{column: column, value: value})
+	}
+}
+
+// NewCachedRepository 创建带缓存的泛型仓库
+//
+// 参数:
+//   - repo: 泛型仓库，通过NewRepository创建
+//   - c: 缓存，DataId需返回数据的主键值
+//   - id: 获取数据的主键值，需与Cache的DataId一致
+//   - opts: 配置选项
+//
+// 使用示例:
+//
+//	repo := NewCachedRepository(NewRepository[*Users](client), userCache,
+//		func(data *Users) any { return data.ID },
+//		CachedOptionUniqueKey("email", func(data *Users) any { return data.Email }),
+//	)
+func NewCachedRepository[T ModelInterface](repo *Repository[T], c *cache.Cache[T], id func(data T) any, opts ...CachedRepositoryOption[T]) *CachedRepository[T] {
+	var model T
+	r := &CachedRepository[T]{
+		Repository: repo,
+		cache:      c,
+		name:       model.TableName(),
+		primaryKey: PrimaryKeyDefault,
+		id:         id,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+
+	return r
+}
+
+// QueryByID 根据主键查询单条记录，优先读取缓存，未命中时查询数据库并写入缓存，记录不存在时返回零值
+func (r *CachedRepository[T]) QueryByID(ctx context.Context, id any) (res T, err error) {
+	getRes := r.cache.GetOrSet(ctx, r.name, id, r.loader(r.primaryKey, id))
+	return getRes.Data(), getRes.Err()
+}
+
+// QueryByUniqueKey 根据唯一键查询单条记录，column需通过 CachedOptionUniqueKey 配置，
+// 优先读取缓存，未命中时查询数据库并写入缓存，记录不存在时返回零值
+func (r *CachedRepository[T]) QueryByUniqueKey(ctx context.Context, column string, value any) (res T, err error) {
+	if !slices.ContainsFunc(r.uniqueKeys, func(v uniqueKey[T]) bool { return v.column == column }) {
+		err = ErrInvalidParams
+		return
+	}
+
+	getRes := r.cache.GetOrSet(ctx, r.name, r.uniqueCacheKey(column, value), r.loader(column, value))
+	return getRes.Data(), getRes.Err()
+}
+
+// loader 返回缓存未命中时根据字段值查询数据库的回源函数
+func (r *CachedRepository[T]) loader(column string, value any) cache.SetFunc[T] {
+	return func(ctx context.Context) result.Interface[T] {
+		return result.New(r.Repository.Query(ctx, &QueryRequest{
+			Query:     fmt.Sprintf("%s = ?", column),
+			Arguments: []any{value},
+		}))
+	}
+}
+
+// Create 创建记录，成功后删除新记录主键和唯一键的缓存（包括负缓存）
+func (r *CachedRepository[T]) Create(ctx context.Context, req *CreateRequest[T]) (rowsAffected int64, err error) {
+	if rowsAffected, err = r.Repository.Create(ctx, req); err != nil {
+		return
+	}
+
+	r.invalidate(ctx, req.Tx, r.cacheKeys(req.Data))
+	return
+}
+
+// Update 更新记录，更新前查询受影响记录的主键和全部唯一键，成功后删除其缓存以及新唯一键的缓存，
+// 因此按map更新非唯一键字段时，受影响记录所有唯一键的缓存同样会被删除。
+// 更新条件为空时会查询全表的主键和唯一键
+func (r *CachedRepository[T]) Update(ctx context.Context, req Updater[T]) (rowsAffected int64, err error) {
+	if err = req.Validate(); err != nil {
+		return
+	}
+
+	keys, err := r.affectedKeys(ctx, req.GetTx(), req.GetQuery(), req.GetArguments(), req.GetLimit())
+	if err != nil {
+		return
+	}
+
+	if rowsAffected, err = r.Repository.Update(ctx, req); err != nil {
+		return
+	}
+
+	switch data := req.GetData().(type) {
+	case T:
+		keys = append(keys, r.uniqueCacheKeys(data)...)
+	case map[string]any:
+		for _, v := range r.uniqueKeys {
+			if value, ok := data[v.column]; ok && !isZero(value) {
+				keys = append(keys, r.uniqueCacheKey(v.column, value))
+			}
+		}
+	}
+
+	r.invalidate(ctx, req.GetTx(), keys)
+	return
+}
+
+// Upsert 创建记录，冲突时更新，成功后删除数据主键和唯一键的缓存
+func (r *CachedRepository[T]) Upsert(ctx context.Context, req *UpsertRequest[T]) (rowsAffected int64, err error) {
+	if rowsAffected, err = r.Repository.Upsert(ctx, req); err != nil {
+		return
+	}
+
+	r.invalidate(ctx, req.Tx, r.cacheKeys(req.Data))
+	return
+}
+
+// Delete 删除记录，删除前查询受影响记录的主键和唯一键，成功后删除其缓存
+func (r *CachedRepository[T]) Delete(ctx context.Context, req *DeleteRequest[T]) (rowsAffected int64, err error) {
+	if err = req.Validate(); err != nil {
+		return
+	}
+
+	keys, err := r.affectedKeys(ctx, req.Tx, req.Query, req.Arguments, req.Limit)
+	if err != nil {
+		return
+	}
+
+	if rowsAffected, err = r.Repository.Delete(ctx, req); err != nil {
+		return
+	}
+
+	r.invalidate(ctx, req.Tx, keys)
+	return
+}
+
+// affectedKeys 查询将被更新或删除的记录，返回其主键和唯一键对应的缓存键
+func (r *CachedRepository[T]) affectedKeys(ctx context.Context, tx *gorm.DB, query string, args []any, limit int) (keys []any, err error) {
+	columns := []string{r.primaryKey}
+	for _, v := range r.uniqueKeys {
+		columns = append(columns, v.column)
+	}
+
+	var (
+		model T
+		rows  []T
+	)
+
+	db := r.Client.DB(tx, false).WithContext(ctx).Model(model).Select(columns)
+	if len(query) > 0 {
+		db = db.Where(query, args...)
+	}
+
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+
+	if err = db.Find(&rows).Error; err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		keys = append(keys, r.cacheKeys(row)...)
+	}
+
+	return
+}
+
+// cacheKeys 返回数据主键和唯一键对应的缓存键，忽略零值
+func (r *CachedRepository[T]) cacheKeys(data T) (keys []any) {
+	if id := r.id(data); !isZero(id) {
+		keys = append(keys, id)
+	}
+	return append(keys, r.uniqueCacheKeys(data)...)
+}
+
+// uniqueCacheKeys 返回数据唯一键对应的缓存键，忽略零值
+func (r *CachedRepository[T]) uniqueCacheKeys(data T) (keys []any) {
+	for _, v := range r.uniqueKeys {
+		if value := v.value(data); !isZero(value) {
+			keys = append(keys, r.uniqueCacheKey(v.column, value))
+		}
+	}
+	return
+}
+
+// uniqueCacheKey 生成唯一键的缓存键，与主键的缓存键区分
+func (r *CachedRepository[T]) uniqueCacheKey(column string, value any) string {
+	return fmt.Sprintf("%s:%v", column, value)
+}
+
+// invalidate 删除缓存，使用事务时延迟到事务提交成功后删除
+func (r *CachedRepository[T]) invalidate(ctx context.Context, tx *gorm.DB, keys []any) {
+	if len(keys) == 0 {
+		return
+	}
+
+	del := func(ctx context.Context) {
+		for _, key := range keys {
+			if res := r.cache.Del(ctx, key); res.Err() != nil {
+				erlogs.Convert(res.Err()).Wrap("cached repository invalidate failed").WarnLog(ctx,
+					erlogs.OptionFields(zap.String("name", r.name), zap.Any("key", key)),
+				)
+			}
+		}
+	}
+
+	AfterCommit(ctx, tx, del)
+}
+
+// isZero 判断值是否为nil或零值
+func isZero(v any) bool {
+	if v == nil {
+		return true
+	}
+	return reflect.ValueOf(v).IsZero()
+}
@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/cache"
+	"github.com/mel0dys0ng/song/pkg/result"
+	"gorm.io/gorm"
+)
+
+func newTestCachedRepository(t *testing.T) (*CachedRepository[*testUser], *cache.Cache[*testUser], *fakeDB) {
+	t.Helper()
+
+	client, fdb := newFakeClient(t)
+	fdb.query = func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(query, "SELECT `id`,`email`,`name`") {
+			return []string{"id", "email", "name"}, [][]driver.Value{{int64(1), "a@x.com", "alice"}}
+		}
+		return nil, nil
+	}
+	fdb.exec = func(string, []driver.NamedValue) (int64, error) { return 1, nil }
+
+	c := cache.New(
+		cache.LRUCache[*testUser](100, time.Minute),
+		cache.IsZero(func(data *testUser) bool { return data == nil }),
+		cache.DataId(func(data *testUser) any { return data.ID }),
+	)
+	t.Cleanup(c.Close)
+
+	repo := NewCachedRepository(NewRepository[*testUser](client), c,
+		func(data *testUser) any { return data.ID },
+		CachedOptionUniqueKey("email", func(data *testUser) any { return data.Email }),
+		CachedOptionUniqueKey("name", func(data *testUser) any { return data.Name }),
+	)
+
+	return repo, c, fdb
+}
+
+// cached 返回缓存中key的数据，未命中时返回nil
+func cached(ctx context.Context, c *cache.Cache[*testUser], key any) *testUser {
+	return c.GetOrSet(ctx, "users", key, func(ctx context.Context) result.Interface[*testUser] {
+		return result.New[*testUser](nil, nil)
+	}).Data()
+}
+
+func TestCachedRepositoryUpdateByMap(t *testing.T) {
+	ctx := context.Background()
+	repo, c, _ := newTestCachedRepository(t)
+
+	user := &testUser{ID: 1, Email: "a@x.com", Name: "alice"}
+	for _, key := range []any{int64(1), "email:a@x.com", "name:alice"} {
+		c.GetOrSet(ctx, "users", key, func(ctx context.Context) result.Interface[*testUser] {
+			return result.New(user, nil)
+		})
+	}
+
+	// 更新非唯一键字段时，受影响记录的主键和所有唯一键缓存均需删除
+	_, err := repo.Update(ctx, &UpdateRequestByMap[*testUser]{
+		Query:     "id = ?",
+		Arguments: []any{1},
+		Data:      map[string]any{"status": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []any{int64(1), "email:a@x.com", "name:alice"} {
+		if data := cached(ctx, c, key); data != nil {
+			t.Fatalf("key %v not invalidated", key)
+		}
+	}
+}
+
+func TestCachedRepositoryUpdateInTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo, c, _ := newTestCachedRepository(t)
+
+	user := &testUser{ID: 1, Email: "a@x.com", Name: "alice"}
+	c.GetOrSet(ctx, "users", int64(1), func(ctx context.Context) result.Interface[*testUser] {
+		return result.New(user, nil)
+	})
+
+	err := repo.Client.Master().Transaction(func(tx *gorm.DB) error {
+		_, err := repo.Update(ctx, &UpdateRequestByMap[*testUser]{
+			Tx:        tx,
+			Query:     "id = ?",
+			Arguments: []any{1},
+			Data:      map[string]any{"status": 2},
+		})
+		if err != nil {
+			return err
+		}
+
+		// 事务提交前缓存保持不变
+		if data := cached(ctx, c, int64(1)); data == nil {
+			t.Error("cache invalidated before commit")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data := cached(ctx, c, int64(1)); data != nil {
+		t.Fatal("cache not invalidated after commit")
+	}
+}
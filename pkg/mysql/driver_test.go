@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mel0dys0ng/song/internal/core/clients/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type (
+	// fakeDB 记录执行的语句，按handler返回查询结果的database/sql驱动
+	fakeDB struct {
+		mu        sync.Mutex
+		stmts     []string
+		commitErr error
+		query     func(query string, args []driver.NamedValue) (columns []string, rows [][]driver.Value)
+		exec      func(query string, args []driver.NamedValue) (rowsAffected int64, err error)
+	}
+
+	fakeConn struct{ db *fakeDB }
+
+	fakeTx struct{ db *fakeDB }
+
+	fakeRows struct {
+		columns []string
+		rows    [][]driver.Value
+	}
+)
+
+type testUser struct {
+	ID           int64
+	Email        string
+	Name         string
+	Status       int
+	FencingToken int64
+}
+
+func (*testUser) TableName() string { return "users" }
+
+// newFakeClient 返回使用fakeDB的客户端
+func newFakeClient(t *testing.T) (*Client, *fakeDB) {
+	t.Helper()
+
+	fdb := &fakeDB{}
+	sqlDB := sql.OpenDB(fdb)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+
+	db, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mysql.NewClient("test", db), fdb
+}
+
+// executed 返回执行过的语句
+func (db *fakeDB) executed() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string{}, db.stmts...)
+}
+
+// count 返回以prefix开头的语句数量
+func (db *fakeDB) count(prefix string) (n int) {
+	for _, stmt := range db.executed() {
+		if strings.HasPrefix(stmt, prefix) {
+			n++
+		}
+	}
+	return
+}
+
+func (db *fakeDB) record(stmt string) {
+	db.mu.Lock()
+	db.stmts = append(db.stmts, stmt)
+	db.mu.Unlock()
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return db }
+func (db *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{db: db}, nil }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { c.db.record("BEGIN"); return &fakeTx{db: c.db}, nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	if c.db.exec == nil {
+		return driver.RowsAffected(0), nil
+	}
+
+	n, err := c.db.exec(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(n), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	if c.db.query == nil {
+		return &fakeRows{}, nil
+	}
+
+	columns, rows := c.db.query(query, args)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.record("COMMIT")
+	return tx.db.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+const (
+	FencingTokenColumnDefault = "fencing_token" // 默认fencing token字段
+)
+
+var (
+	ErrStaleFencingToken = errors.New("stale fencing token")
+)
+
+// FencingUpdate 携带分布式锁fencing token（lock.Core.Token）条件更新记录：仅更新token字段不大于token的记录，
+// 并将token字段更新为token；存在token字段大于token的记录时说明锁已被新的持有者获取，返回 ErrStaleFencingToken。
+// token字段需为整数类型且默认值为0。同一持锁方可使用相同token多次更新，
+// token需由开启 lock.Fencing 的单节点互斥锁生成，Redlock不保证token严格递增
+//
+// 参数:
+//   - repo: 泛型仓库
+//   - column: token字段，为空时使用 FencingTokenColumnDefault
+//   - token: 持锁时获取的fencing token，需大于0
+//   - req: 更新请求
+//
+// 使用示例:
+//
+//	locker, err := lock.New(lock.RedisClient(client), lock.Fencing(true))
+//	...
+//	c, ok, err := locker.Lock(ctx, "order:1")
+//	...
+//	_, err = FencingUpdate(ctx, repo, "", c.Token(), &UpdateRequestByMap[*Orders]{
+//		Query:     "id = ?",
+//		Arguments: []any{1},
+//		Data:      map[string]any{"status": 2},
+//	})
+func FencingUpdate[T ModelInterface](ctx context.Context, repo *Repository[T], column string, token int64, req *UpdateRequestByMap[T]) (rowsAffected int64, err error) {
+	if err = req.Validate(); err != nil {
+		return
+	}
+
+	if token <= 0 {
+		err = ErrInvalidParams
+		return
+	}
+
+	if len(column) == 0 {
+		column = FencingTokenColumnDefault
+	}
+
+	query, args := fencingQuery(req, fmt.Sprintf("%s <= ?", column), token)
+
+	data := maps.Clone(req.Data)
+	data[column] = token
+
+	fields := req.Fields
+	if len(fields) > 0 {
+		fields = append(append([]string{}, fields...), column)
+	}
+
+	rowsAffected, err = repo.Update(ctx, &UpdateRequestByMap[T]{
+		Tx:        req.Tx,
+		Fields:    fields,
+		Query:     query,
+		Arguments: args,
+		Data:      data,
+		Limit:     req.Limit,
+	})
+	if err != nil || rowsAffected > 0 {
+		return
+	}
+
+	// 未更新任何记录时，区分token过期与记录不存在或数据未变化
+	staleQuery, staleArgs := fencingQuery(req, fmt.Sprintf("%s > ?", column), token)
+
+	count, err := repo.QueryCount(ctx, &QueryCountRequest{
+		Tx:        req.Tx,
+		Query:     staleQuery,
+		Arguments: staleArgs,
+	})
+	if err != nil {
+		return
+	}
+
+	if count > 0 {
+		err = ErrStaleFencingToken
+	}
+
+	return
+}
+
+// fencingQuery 在更新条件上追加token条件
+func fencingQuery[T ModelInterface](req *UpdateRequestByMap[T], cond string, token int64) (query string, args []any) {
+	if len(req.Query) == 0 {
+		return cond, []any{token}
+	}
+	return fmt.Sprintf("(%s) AND %s", req.Query, cond), append(append([]any{}, req.Arguments...), token)
+}
@@ -0,0 +1,66 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFencingUpdate(t *testing.T) {
+	ctx := context.Background()
+	client, fdb := newFakeClient(t)
+	repo := NewRepository[*testUser](client)
+
+	var (
+		updated int64 // UPDATE影响的行数
+		stale   int64 // token字段大于token的记录数
+		update  string
+		args    []driver.NamedValue
+	)
+	fdb.exec = func(query string, a []driver.NamedValue) (int64, error) {
+		update, args = query, a
+		return updated, nil
+	}
+	fdb.query = func(query string, _ []driver.NamedValue) ([]string, [][]driver.Value) {
+		return []string{"count(*)"}, [][]driver.Value{{stale}}
+	}
+
+	req := func() *UpdateRequestByMap[*testUser] {
+		return &UpdateRequestByMap[*testUser]{
+			Query:     "id = ?",
+			Arguments: []any{1},
+			Data:      map[string]any{"status": 2},
+		}
+	}
+
+	updated = 1
+	n, err := FencingUpdate(ctx, repo, "", 7, req())
+	if err != nil || n != 1 {
+		t.Fatalf("FencingUpdate = %d, %v", n, err)
+	}
+	if !strings.Contains(update, "`fencing_token`=?") || !strings.Contains(update, "(id = ?) AND fencing_token <= ?") {
+		t.Fatalf("update = %s", update)
+	}
+	if last := args[len(args)-1].Value; last != int64(7) {
+		t.Fatalf("token argument = %v, want 7", last)
+	}
+
+	// 未更新且存在更大的token时为过期token
+	updated, stale = 0, 1
+	if _, err = FencingUpdate(ctx, repo, "", 7, req()); !errors.Is(err, ErrStaleFencingToken) {
+		t.Fatalf("err = %v, want %v", err, ErrStaleFencingToken)
+	}
+
+	// 未更新且不存在更大的token时，记录不存在或数据未变化
+	stale = 0
+	if n, err = FencingUpdate(ctx, repo, "", 7, req()); err != nil || n != 0 {
+		t.Fatalf("FencingUpdate = %d, %v", n, err)
+	}
+
+	// 未开启fencing的锁token为0
+	if _, err = FencingUpdate(ctx, repo, "", 0, req()); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidParams)
+	}
+}
@@ -8,17 +8,20 @@ import (
 )
 
 type (
-	Client                          = mysql.Client
-	Option                          = mysql.Option
-	JoinQueryArguments              = mysql.JoinQueryArguments
-	QueryRequest                    = mysql.QueryRequest
-	QueryListRequest                = mysql.QueryListRequest
-	QueryCountRequest               = mysql.QueryCountRequest
-	CreateRequest[T ModelInterface] = mysql.CreateRequest[T]
-	UpdateRequest[T ModelInterface] = mysql.UpdateRequest[T]
-	DeleteRequest[T ModelInterface] = mysql.DeleteRequest[T]
-	ModelInterface                  = mysql.ModelInterface
-	Repository[T ModelInterface]    = mysql.Repository[T]
+	Client                               = mysql.Client
+	Option                               = mysql.Option
+	JoinQueryArguments                   = mysql.JoinQueryArguments
+	QueryRequest                         = mysql.QueryRequest
+	QueryListRequest                     = mysql.QueryListRequest
+	QueryCountRequest                    = mysql.QueryCountRequest
+	CreateRequest[T ModelInterface]      = mysql.CreateRequest[T]
+	UpdateRequest[T ModelInterface]      = mysql.UpdateRequest[T]
+	UpdateRequestByMap[T ModelInterface] = mysql.UpdateRequestByMap[T]
+	DeleteRequest[T ModelInterface]      = mysql.DeleteRequest[T]
+	UpsertRequest[T ModelInterface]      = mysql.UpsertRequest[T]
+	Updater[T ModelInterface]            = mysql.Updater[T]
+	ModelInterface                       = mysql.ModelInterface
+	Repository[T ModelInterface]         = mysql.Repository[T]
 )
 
 var (
@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/mel0dys0ng/song/internal/core/clients/mysql"
+	"gorm.io/gorm"
+)
+
+// AfterCommit 注册事务提交成功后执行的回调，回调按注册顺序执行，回滚或提交失败时丢弃，tx为nil时立即执行。
+// 通过 Client.Begin 或 Client.Master().Transaction 开启的事务，直接调用 tx.Commit()、
+// 在派生的 *gorm.DB 或嵌套事务中注册均可生效
+//
+// 使用示例:
+//
+//	err := client.Master().Transaction(func(tx *gorm.DB) error {
+//		AfterCommit(ctx, tx, func(ctx context.Context) { ... })
+//		return nil
+//	})
+func AfterCommit(ctx context.Context, tx *gorm.DB, fn func(ctx context.Context)) {
+	mysql.AfterCommit(ctx, tx, fn)
+}
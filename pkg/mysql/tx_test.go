@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	client, fdb := newFakeClient(t)
+
+	var calls []string
+	hook := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) { calls = append(calls, name) }
+	}
+
+	tx := client.Begin()
+	AfterCommit(ctx, tx, hook("a"))
+	// 派生的 *gorm.DB 与tx共享事务
+	AfterCommit(ctx, tx.WithContext(ctx).Where("id = ?", 1), hook("b"))
+	if len(calls) != 0 {
+		t.Fatalf("hooks ran before commit: %v", calls)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 || calls[0] != "a" || calls[1] != "b" {
+		t.Fatalf("calls = %v, want [a b]", calls)
+	}
+
+	// 提交后回调已释放，不会重复执行
+	_ = tx.Commit()
+	if len(calls) != 2 {
+		t.Fatalf("hooks ran twice: %v", calls)
+	}
+
+	if fdb.count("COMMIT") == 0 {
+		t.Fatal("transaction not committed")
+	}
+}
+
+func TestAfterCommitRollback(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newFakeClient(t)
+
+	called := false
+	tx := client.Begin()
+	AfterCommit(ctx, tx, func(ctx context.Context) { called = true })
+
+	if err := tx.Rollback().Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if called {
+		t.Fatal("hook ran after rollback")
+	}
+}
+
+func TestAfterCommitCommitError(t *testing.T) {
+	ctx := context.Background()
+	client, fdb := newFakeClient(t)
+	fdb.commitErr = errors.New("commit failed")
+
+	called := false
+	tx := client.Begin()
+	AfterCommit(ctx, tx, func(ctx context.Context) { called = true })
+
+	if err := tx.Commit().Error; err == nil {
+		t.Fatal("commit error not returned")
+	}
+
+	if called {
+		t.Fatal("hook ran after failed commit")
+	}
+}
+
+func TestAfterCommitTransaction(t *testing.T) {
+	ctx := context.Background()
+	client, fdb := newFakeClient(t)
+
+	var calls []string
+	err := client.Master().Transaction(func(tx *gorm.DB) error {
+		AfterCommit(ctx, tx, func(ctx context.Context) { calls = append(calls, "outer") })
+
+		return tx.Transaction(func(tx *gorm.DB) error {
+			AfterCommit(ctx, tx.Session(&gorm.Session{}), func(ctx context.Context) { calls = append(calls, "nested") })
+			if len(calls) != 0 {
+				t.Errorf("hooks ran before commit: %v", calls)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 || calls[0] != "outer" || calls[1] != "nested" {
+		t.Fatalf("calls = %v, want [outer nested]", calls)
+	}
+
+	if fdb.count("SAVEPOINT") != 1 {
+		t.Fatalf("nested transaction did not use a savepoint: %v", fdb.executed())
+	}
+}
+
+func TestAfterCommitTransactionError(t *testing.T) {
+	ctx := context.Background()
+	client, fdb := newFakeClient(t)
+
+	called := false
+	errAbort := errors.New("abort")
+	err := client.Master().Transaction(func(tx *gorm.DB) error {
+		AfterCommit(ctx, tx, func(ctx context.Context) { called = true })
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err = %v, want %v", err, errAbort)
+	}
+
+	if called || fdb.count("ROLLBACK") != 1 {
+		t.Fatalf("called = %v, statements = %v", called, fdb.executed())
+	}
+}
+
+func TestAfterCommitWithoutTx(t *testing.T) {
+	called := false
+	AfterCommit(context.Background(), nil, func(ctx context.Context) { called = true })
+	if !called {
+		t.Fatal("hook did not run without transaction")
+	}
+}
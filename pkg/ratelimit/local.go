@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+type (
+	// localLimiter 进程内的令牌桶限流器，每个key一个令牌桶，仅对当前进程生效
+	localLimiter struct {
+		mu      sync.Mutex
+		buckets *lru.Cache[string, *bucket]
+	}
+
+	// bucket 令牌桶，容量为Burst，每 Period/Rate 恢复一个令牌
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+// NewLocal 创建进程内的令牌桶限流器，多实例部署时每个实例单独计数
+func NewLocal(opts ...Option) Limiter {
+	o := newOptions(opts)
+	buckets, _ := lru.New[string, *bucket](o.maxKeys)
+	return &localLimiter{buckets: buckets}
+}
+
+// Allow 消耗key的一个令牌，令牌不足时拒绝
+func (l *localLimiter) Allow(ctx context.Context, key string, limit Limit) (res Result, err error) {
+	if key == "" {
+		return res, ErrKeyEmpty
+	}
+
+	limit, err = limit.validate()
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(limit.Burst)
+	interval := limit.interval()
+
+	b, ok := l.buckets.Get(key)
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets.Add(key, b)
+	}
+
+	// 按流逝的时间补充令牌，不超过容量
+	b.tokens = min(capacity, b.tokens+float64(now.Sub(b.last))/float64(interval))
+	b.last = now
+
+	res.Limit = limit.Burst
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+
+	res.Remaining = int64(b.tokens)
+	res.ResetAfter = time.Duration((capacity - b.tokens) * float64(interval))
+
+	return
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+const (
+	KeyPrefixDefault = "ratelimit:" // Redis key前缀
+	MaxKeysDefault   = 10000        // 本地限流器最多保留的key数量
+)
+
+type (
+	// Option 限流器配置
+	Option func(o *options)
+
+	options struct {
+		keyPrefix string // Redis key前缀
+		maxKeys   int    // 本地限流器最多保留的key数量，超出后淘汰最久未使用的key
+	}
+)
+
+func newOptions(opts []Option) options {
+	o := options{
+		keyPrefix: KeyPrefixDefault,
+		maxKeys:   MaxKeysDefault,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	if o.maxKeys <= 0 {
+		o.maxKeys = MaxKeysDefault
+	}
+
+	return o
+}
+
+// KeyPrefix 配置Redis限流器的key前缀，默认 "ratelimit:"
+func KeyPrefix(s string) Option {
+	return func(o *options) {
+		o.keyPrefix = s
+	}
+}
+
+// MaxKeys 配置本地限流器最多保留的key数量，默认10000，超出后淘汰最久未使用的key（相当于重置其配额）
+func MaxKeys(n int) Option {
+	return func(o *options) {
+		o.maxKeys = n
+	}
+}
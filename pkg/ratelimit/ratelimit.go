@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrLimitInvalid = errors.New("rate limit is invalid, rate and period must be positive")
+	ErrKeyEmpty     = errors.New("rate limit key is empty")
+)
+
+type (
+	// Limiter 限流器，按key统计请求并判断是否放行
+	Limiter interface {
+		// Allow 消耗key的一个配额，返回是否放行及当前配额状态
+		Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	}
+
+	// Limit 限流规则：每Period时间内平均放行Rate个请求，最多允许Burst个请求突发
+	Limit struct {
+		Rate   int64         // 每个周期放行的请求数
+		Period time.Duration // 周期
+		Burst  int64         // 突发请求数，即配额上限，<=0时等于Rate
+	}
+
+	// Result 限流结果
+	Result struct {
+		Allowed    bool          // 是否放行
+		Limit      int64         // 配额上限，即Burst
+		Remaining  int64         // 剩余配额
+		RetryAfter time.Duration // 被拒绝时，距离下一个配额可用的时间；放行时为0
+		ResetAfter time.Duration // 距离配额完全恢复的时间
+	}
+)
+
+// PerSecond 每秒放行rate个请求
+func PerSecond(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute 每分钟放行rate个请求
+func PerMinute(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// PerHour 每小时放行rate个请求
+func PerHour(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// WithBurst 设置突发请求数
+func (l Limit) WithBurst(burst int64) Limit {
+	l.Burst = burst
+	return l
+}
+
+// validate 校验限流规则，Burst未设置时使用Rate
+func (l Limit) validate() (Limit, error) {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return l, ErrLimitInvalid
+	}
+
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+
+	return l, nil
+}
+
+// interval 每个配额的恢复间隔
+func (l Limit) interval() time.Duration {
+	return max(l.Period/time.Duration(l.Rate), time.Microsecond)
+}
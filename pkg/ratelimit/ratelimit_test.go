@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) Limiter {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return NewRedis(client)
+}
+
+func TestLimiter(t *testing.T) {
+	limiters := map[string]Limiter{
+		"local": NewLocal(),
+		"redis": newTestRedis(t),
+	}
+
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := PerMinute(60).WithBurst(3)
+
+			// 突发配额用完前放行，剩余配额递减
+			for i := int64(2); i >= 0; i-- {
+				res, err := l.Allow(ctx, "user:1", limit)
+				if err != nil || !res.Allowed || res.Remaining != i || res.Limit != 3 {
+					t.Fatalf("Allow: %+v, %v, want allowed with remaining %d", res, err, i)
+				}
+			}
+
+			res, err := l.Allow(ctx, "user:1", limit)
+			if err != nil || res.Allowed || res.Remaining != 0 {
+				t.Fatalf("Allow: %+v, %v, want rejected", res, err)
+			}
+
+			// 每秒恢复一个配额
+			if res.RetryAfter <= 0 || res.RetryAfter > time.Second || res.ResetAfter <= 2*time.Second {
+				t.Fatalf("Allow: retry after %v, reset after %v", res.RetryAfter, res.ResetAfter)
+			}
+
+			// 不同key单独计数
+			if res, err = l.Allow(ctx, "user:2", limit); err != nil || !res.Allowed {
+				t.Fatalf("Allow other key: %+v, %v", res, err)
+			}
+
+			if _, err = l.Allow(ctx, "user:1", Limit{}); !errors.Is(err, ErrLimitInvalid) {
+				t.Fatalf("Allow: %v, want %v", err, ErrLimitInvalid)
+			}
+		})
+	}
+}
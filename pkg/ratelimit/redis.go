@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// gcraScript 使用GCRA（通用信元速率算法）原子地判断并更新key的理论到达时间（TAT），时间取自Redis服务器，不受各实例时钟偏差影响
+	//   - KEYS: 限流key
+	//   - ARGV: 配额恢复间隔（微秒）, 突发容忍时间（微秒，恢复间隔 * Burst）
+	//
+	// 返回 {是否放行, 剩余配额, 重试等待时间（微秒）, 配额完全恢复时间（微秒）}
+	gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+local newTat = tat + interval
+local diff = now - (newTat - tolerance)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', newTat), 'PX', math.ceil((newTat - now) / 1000))
+return {1, math.floor(diff / interval), 0, newTat - now}
+`)
+)
+
+type (
+	// redisLimiter 基于Redis的GCRA限流器，多实例共享配额，效果等同于平滑恢复的令牌桶
+	redisLimiter struct {
+		client redis.UniversalClient
+		options
+	}
+)
+
+// NewRedis 创建基于Redis的GCRA限流器，多实例部署时共享配额。
+// 每个key仅保存一个理论到达时间，配额恢复后自动过期
+func NewRedis(client redis.UniversalClient, opts ...Option) Limiter {
+	return &redisLimiter{client: client, options: newOptions(opts)}
+}
+
+// Allow 消耗key的一个配额，配额不足时拒绝
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit Limit) (res Result, err error) {
+	if key == "" {
+		return res, ErrKeyEmpty
+	}
+
+	limit, err = limit.validate()
+	if err != nil {
+		return
+	}
+
+	interval := limit.interval().Microseconds()
+	values, err := gcraScript.Run(ctx, l.client, []string{l.keyPrefix + key}, interval, interval*limit.Burst).Int64Slice()
+	if err != nil {
+		return
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/mel0dys0ng/song/internal/core/clients/resty"
+	"github.com/mel0dys0ng/song/pkg/breaker"
 )
 
 func OptionDebug(b bool) resty.Option {
@@ -53,3 +54,7 @@ func OptionSignTTL(t int) resty.Option {
 func OptionSignSecret(s string) resty.Option {
 	return resty.SignSecret(s)
 }
+
+func OptionBreaker(opts ...breaker.Option) resty.Option {
+	return resty.Breaker(opts...)
+}
@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+type (
+	// BackoffFunc 重试间隔策略
+	//
+	// 参数:
+	//   - attempt: 已失败的执行次数，从1开始
+	//   - prev: 上一次的重试间隔，首次重试时为0
+	//
+	// 返回值:
+	//   - 下一次重试前等待的时间
+	BackoffFunc func(attempt uint32, prev time.Duration) time.Duration
+)
+
+// Constant 固定间隔策略，每次重试前等待d
+func Constant(d time.Duration) BackoffFunc {
+	return func(attempt uint32, prev time.Duration) time.Duration {
+		return d
+	}
+}
+
+// Linear 线性递增策略，第n次重试前等待 base*n，最大不超过maxDelay（maxDelay<=0时不限制）
+func Linear(base, maxDelay time.Duration) BackoffFunc {
+	return func(attempt uint32, prev time.Duration) time.Duration {
+		return capDelay(base*time.Duration(attempt), maxDelay)
+	}
+}
+
+// Exponential 指数递增策略，第n次重试前等待 base*2^(n-1)，最大不超过maxDelay（maxDelay<=0时不限制）
+func Exponential(base, maxDelay time.Duration) BackoffFunc {
+	return func(attempt uint32, prev time.Duration) time.Duration {
+		d := base
+		for i := uint32(1); i < attempt && d > 0; i++ {
+			// 溢出或超过上限时提前结束
+			if d > math.MaxInt64/2 || (maxDelay > 0 && d >= maxDelay) {
+				break
+			}
+			d *= 2
+		}
+		return capDelay(d, maxDelay)
+	}
+}
+
+// DecorrelatedJitter 去相关抖动策略，每次重试前等待 [base, prev*3] 范围内的随机时间，最大不超过maxDelay（maxDelay<=0时不限制），
+// 避免大量调用方同时失败后按相同节奏重试
+func DecorrelatedJitter(base, maxDelay time.Duration) BackoffFunc {
+	return func(attempt uint32, prev time.Duration) time.Duration {
+		if base <= 0 {
+			return 0
+		}
+
+		upper := max(prev*3, base)
+		return capDelay(base+rand.N(upper-base+1), maxDelay)
+	}
+}
+
+// capDelay 限制重试间隔不超过maxDelay，maxDelay<=0时不限制
+func capDelay(d, maxDelay time.Duration) time.Duration {
+	if maxDelay > 0 && d > maxDelay {
+		return maxDelay
+	}
+	return d
+}
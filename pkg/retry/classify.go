@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mel0dys0ng/song/pkg/breaker"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+)
+
+type (
+	// coder 带状态码的错误，如erlogs
+	coder interface {
+		GetCode() int64
+	}
+)
+
+// Retryable 默认的可重试错误判断：单次执行超时（ErrAttemptTimeout）重试，ctx取消或超时、熔断器打开不重试；
+// 带erlogs状态码的错误中，请求类错误（4xxxx，操作频率过快、请求次数过多除外）和服务参数错误不重试，其余错误均重试
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, breaker.ErrCircuitOpen) {
+		return false
+	}
+
+	var c coder
+	if !errors.As(err, &c) {
+		return true
+	}
+
+	return RetryableCode(c.GetCode())
+}
+
+// RetryableCode 判断erlogs状态码对应的错误是否可重试
+func RetryableCode(code int64) bool {
+	switch code {
+	case erlogs.FrequencyLimit.GetCode(), erlogs.TooManyRequests.GetCode():
+		return true
+	case erlogs.InvalidParams.GetCode():
+		return false
+	}
+
+	return code < erlogs.BadRequest.GetCode() || code >= erlogs.ServerError.GetCode()
+}
@@ -1,8 +1,11 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"time"
+
+	"github.com/mel0dys0ng/song/pkg/breaker"
 )
 
 // SingleflightKey 设置singleflight Key，默认为空，不启用；若不为空，则使用该Key启用singleflight
@@ -58,3 +61,69 @@ func Num(i uint32) Option {
 		},
 	}
 }
+
+// Backoff 设置重试间隔策略，如 Constant、Linear、Exponential、DecorrelatedJitter；设置后忽略 Delay
+func Backoff(b BackoffFunc) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.backoff = b
+		},
+	}
+}
+
+// RetryIf 设置判断错误是否可重试的函数，返回false时立即返回结果，默认为 Retryable
+func RetryIf(fn func(err error) bool) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.retryIf = fn
+		},
+	}
+}
+
+// Budget 设置总时间预算，从首次执行开始计算，等待下一次重试会超出预算时不再重试，默认不限制
+func Budget(d time.Duration) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.budget = d
+		},
+	}
+}
+
+// OnRetry 设置重试前回调，attempt为已失败的执行次数（从1开始），err为本次执行的错误
+func OnRetry(fn func(ctx context.Context, attempt uint32, err error)) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.onRetry = fn
+		},
+	}
+}
+
+// Breaker 设置熔断器，每次执行前经过熔断器判断，熔断器打开时不再执行并返回 breaker.ErrCircuitOpen
+func Breaker(b *breaker.Breaker) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.breaker = b
+		},
+	}
+}
+
+// AttemptTimeout 设置单次执行的超时时间，默认不限制。
+// 超时的错误包装为 ErrAttemptTimeout，ctx未取消时可重试（Retryable 返回true）
+func AttemptTimeout(d time.Duration) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.attemptTimeout = d
+		},
+	}
+}
+
+// Hedge 启用对冲执行：每次执行在delay内未返回结果时再发起一次执行，最多发起n次（n为0时为1），
+// 返回第一个成功的结果并取消其余执行。对冲执行不计入重试次数，适用于幂等的读操作
+func Hedge(delay time.Duration, n uint32) Option {
+	return Option{
+		apply: func(r *retry) {
+			r.hedgeDelay = delay
+			r.hedgeNum = n
+		},
+	}
+}
@@ -2,10 +2,13 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/mel0dys0ng/song/pkg/breaker"
 	"github.com/mel0dys0ng/song/pkg/result"
+	"github.com/mel0dys0ng/song/pkg/safe"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -15,14 +18,24 @@ const (
 )
 
 var (
+	ErrAttemptTimeout = errors.New("retry attempt timeout")
+
 	sg = &singleflight.Group{}
 )
 
 type (
 	retry struct {
-		num             uint32        // 重试最大次数
-		delay           time.Duration // 重试间隔时间
-		singleflightKey string        // singleflight Key，默认为空，不启用；若不为空，则使用该Key启用singleflight
+		num             uint32                                               // 重试最大次数
+		delay           time.Duration                                        // 重试间隔时间
+		backoff         BackoffFunc                                          // 重试间隔策略，为空时按delay时间的1/2递增
+		retryIf         func(err error) bool                                 // 判断错误是否可重试
+		budget          time.Duration                                        // 总时间预算，0表示不限制
+		onRetry         func(ctx context.Context, attempt uint32, err error) // 重试前回调
+		breaker         *breaker.Breaker                                     // 熔断器，打开时停止执行
+		attemptTimeout  time.Duration                                        // 单次执行的超时时间，0表示不限制
+		hedgeDelay      time.Duration                                        // 对冲执行的延迟时间，0表示不启用对冲
+		hedgeNum        uint32                                               // 每次执行最多发起的对冲执行数
+		singleflightKey string                                               // singleflight Key，默认为空，不启用；若不为空，则使用该Key启用singleflight
 	}
 
 	Option struct {
@@ -32,10 +45,11 @@ type (
 	HandlerFunc[T any] func(ctx context.Context) result.Interface[T]
 )
 
-// Do 执行handler，若执行失败后进行重试。
-// 重试间隔按照delay时间的1/2递增（delay>0时）。
-// 默认值: Num=3, Delay=10ms, SingleflightKey=""。
-// res = handler(ctx)。
+// Do 执行handler，若执行失败且错误可重试（RetryIf，默认为 Retryable）则进行重试。
+// 重试间隔由 Backoff 决定，未配置时按照delay时间的1/2递增；等待期间ctx取消或超出总时间预算（Budget）时停止重试。
+// 每次执行可通过 AttemptTimeout 限制超时时间，通过 Hedge 启用对冲执行。
+// 默认值: Num=3, Delay=20ms, RetryIf=Retryable, SingleflightKey=""。
+// res = handler(ctx)，停止重试时返回最后一次执行的结果。
 func Do[T any](ctx context.Context, handler HandlerFunc[T], opts ...Option) (res result.Interface[T]) {
 	rt := newRetry(opts)
 
@@ -49,10 +63,20 @@ func Do[T any](ctx context.Context, handler HandlerFunc[T], opts ...Option) (res
 	return do(ctx, rt, handler)
 }
 
+// Call 执行fn，重试规则与 Do 相同，适用于返回 (T, error) 的普通函数。
+// 停止重试时返回最后一次执行的结果和错误
+func Call[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts ...Option) (T, error) {
+	res := Do(ctx, func(ctx context.Context) result.Interface[T] {
+		return result.New(fn(ctx))
+	}, opts...)
+	return res.Data(), res.Err()
+}
+
 func newRetry(opts []Option) *retry {
 	rt := &retry{
 		num:             NumDefault,
 		delay:           DelayDefault,
+		retryIf:         Retryable,
 		singleflightKey: "",
 	}
 
@@ -70,6 +94,14 @@ func newRetry(opts []Option) *retry {
 		rt.delay = DelayDefault
 	}
 
+	if rt.retryIf == nil {
+		rt.retryIf = Retryable
+	}
+
+	if rt.hedgeDelay > 0 && rt.hedgeNum == 0 {
+		rt.hedgeNum = 1
+	}
+
 	return rt
 }
 
@@ -86,15 +118,146 @@ func do[T any](ctx context.Context, rt *retry, handler HandlerFunc[T]) (res resu
 		}
 	}()
 
-	for i := uint32(0); i < rt.num; i++ {
-		if res = handler(ctx); res.Err() == nil {
-			break
+	var (
+		start = time.Now()
+		delay time.Duration
+	)
+
+	for attempt := uint32(1); ; attempt++ {
+		res = hedge(ctx, rt, handler)
+		err := res.Err()
+		if err == nil || attempt >= rt.num || !rt.retryIf(err) {
+			return
+		}
+
+		delay = rt.nextDelay(attempt, delay)
+
+		// 等待后超出总时间预算时不再重试
+		if rt.budget > 0 && time.Since(start)+delay > rt.budget {
+			return
+		}
+
+		if rt.onRetry != nil {
+			rt.onRetry(ctx, attempt, err)
 		}
 
-		if rt.delay > 0 {
-			time.Sleep(rt.delay * time.Duration((i+2)/2))
+		if !sleep(ctx, delay) {
+			return
 		}
 	}
+}
+
+// hedge 执行一次handler，启用对冲时若hedgeDelay内未返回结果则再发起一次执行，最多发起hedgeNum次对冲执行。
+// 返回第一个成功的结果并取消其余执行；全部失败时返回最后一个失败的结果
+func hedge[T any](ctx context.Context, rt *retry, handler HandlerFunc[T]) (res result.Interface[T]) {
+	if rt.hedgeDelay <= 0 {
+		return attempt(ctx, rt, handler)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make(chan result.Interface[T], rt.hedgeNum+1)
+		launched uint32
+		inflight int
+	)
+
+	launch := func() {
+		launched++
+		inflight++
+		go func() {
+			r, err := safe.F(ctx, func(ctx context.Context) (result.Interface[T], error) {
+				return attempt(ctx, rt, handler), nil
+			})
+			if err != nil {
+				r = result.Error[T](err)
+			}
+			results <- r
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(rt.hedgeDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case res = <-results:
+			if inflight--; res.Err() == nil || inflight == 0 {
+				return
+			}
+		case <-timer.C:
+			if launched <= rt.hedgeNum {
+				launch()
+				timer.Reset(rt.hedgeDelay)
+			}
+		case <-ctx.Done():
+			return result.Error[T](ctx.Err())
+		}
+	}
+}
 
-	return
+// attempt 执行一次handler，配置单次执行超时时间时，超时的错误包装为 ErrAttemptTimeout
+func attempt[T any](ctx context.Context, rt *retry, handler HandlerFunc[T]) result.Interface[T] {
+	if rt.attemptTimeout <= 0 {
+		return execute(ctx, rt, handler)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, rt.attemptTimeout)
+	defer cancel()
+
+	res := execute(attemptCtx, rt, handler)
+	if err := res.Err(); err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		res.SetErr(fmt.Errorf("%w: %w", ErrAttemptTimeout, err))
+	}
+
+	return res
+}
+
+// execute 执行一次handler，配置熔断器时通过熔断器执行，熔断器打开时返回 breaker.ErrCircuitOpen
+func execute[T any](ctx context.Context, rt *retry, handler HandlerFunc[T]) (res result.Interface[T]) {
+	if rt.breaker == nil {
+		return handler(ctx)
+	}
+
+	done, err := rt.breaker.Allow()
+	if err != nil {
+		return result.Error[T](err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			done(fmt.Errorf("panic occurred: %v", r))
+			panic(r)
+		}
+		done(res.Err())
+	}()
+
+	return handler(ctx)
+}
+
+// nextDelay 计算第attempt次失败后的重试间隔
+func (rt *retry) nextDelay(attempt uint32, prev time.Duration) time.Duration {
+	if rt.backoff != nil {
+		return max(rt.backoff(attempt, prev), 0)
+	}
+	return rt.delay * time.Duration((attempt+1)/2)
+}
+
+// sleep 等待d，ctx取消时提前返回false
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
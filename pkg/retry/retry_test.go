@@ -0,0 +1,180 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mel0dys0ng/song/pkg/breaker"
+	"github.com/mel0dys0ng/song/pkg/erlogs"
+	"github.com/mel0dys0ng/song/pkg/metas"
+	"github.com/mel0dys0ng/song/pkg/result"
+)
+
+func TestDoRetryIf(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("failed"), 3},
+		{"server error", erlogs.MySQLError, 3},
+		{"too many requests", erlogs.TooManyRequests, 3},
+		{"invalid arguments", erlogs.InvalidArguments, 1},
+		{"invalid params", erlogs.InvalidParams, 1},
+		{"canceled", context.Canceled, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls, retries int
+			res := Do(ctx, func(ctx context.Context) result.Interface[int] {
+				calls++
+				return result.Error[int](tt.err)
+			}, Backoff(Constant(time.Millisecond)), OnRetry(func(ctx context.Context, attempt uint32, err error) {
+				retries++
+				if int(attempt) != retries || err != tt.err {
+					t.Errorf("OnRetry(%d, %v)", attempt, err)
+				}
+			}))
+
+			if calls != tt.want || retries != tt.want-1 || res.Err() != tt.err {
+				t.Fatalf("calls = %d, retries = %d, err = %v", calls, retries, res.Err())
+			}
+		})
+	}
+}
+
+func TestDoStopsRetrying(t *testing.T) {
+	failed := func(ctx context.Context) result.Interface[int] {
+		return result.Error[int](errors.New("failed"))
+	}
+
+	// 等待期间ctx取消时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_ = Do(ctx, failed, Num(10), Backoff(Constant(time.Second)))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Do ignored ctx cancellation, took %v", elapsed)
+	}
+
+	// 超出总时间预算时不再重试
+	calls := 0
+	_ = Do(context.Background(), func(ctx context.Context) result.Interface[int] {
+		calls++
+		return failed(ctx)
+	}, Num(10), Backoff(Constant(40*time.Millisecond)), Budget(110*time.Millisecond))
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3 within budget", calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, maxDelay := 10*time.Millisecond, 100*time.Millisecond
+
+	for attempt, want := range []time.Duration{10, 20, 40, 80, 100, 100} {
+		if d := Exponential(base, maxDelay)(uint32(attempt+1), 0); d != want*time.Millisecond {
+			t.Fatalf("Exponential(%d) = %v, want %v", attempt+1, d, want*time.Millisecond)
+		}
+	}
+
+	if d := Exponential(base, 0)(1000, 0); d <= 0 {
+		t.Fatalf("Exponential overflow: %v", d)
+	}
+
+	if d := Linear(base, maxDelay)(3, 0); d != 30*time.Millisecond {
+		t.Fatalf("Linear(3) = %v", d)
+	}
+
+	var prev time.Duration
+	for attempt := uint32(1); attempt <= 20; attempt++ {
+		prev = DecorrelatedJitter(base, maxDelay)(attempt, prev)
+		if prev < base || prev > maxDelay {
+			t.Fatalf("DecorrelatedJitter(%d) = %v, out of [%v, %v]", attempt, prev, base, maxDelay)
+		}
+	}
+}
+
+func TestCallAttemptTimeout(t *testing.T) {
+	// 单次执行超时后重试，第二次执行成功
+	calls := 0
+	v, err := Call(context.Background(), func(ctx context.Context) (int, error) {
+		if calls++; calls == 1 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return 42, nil
+	}, AttemptTimeout(20*time.Millisecond), Backoff(Constant(time.Millisecond)))
+	if v != 42 || err != nil || calls != 2 {
+		t.Fatalf("Call = %d, %v, calls = %d", v, err, calls)
+	}
+
+	// 重试次数用完时返回 ErrAttemptTimeout
+	_, err = Call(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, Num(2), AttemptTimeout(10*time.Millisecond), Backoff(Constant(time.Millisecond)))
+	if !errors.Is(err, ErrAttemptTimeout) {
+		t.Fatalf("Call: %v, want %v", err, ErrAttemptTimeout)
+	}
+}
+
+func TestCallHedge(t *testing.T) {
+	var calls atomic.Int32
+	canceled := make(chan struct{})
+
+	// 首次执行阻塞，对冲执行成功后取消首次执行
+	start := time.Now()
+	v, err := Call(context.Background(), func(ctx context.Context) (int, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return 0, ctx.Err()
+		}
+		return 2, nil
+	}, Hedge(20*time.Millisecond, 1))
+	if v != 2 || err != nil || calls.Load() != 2 {
+		t.Fatalf("Call = %d, %v, calls = %d", v, err, calls.Load())
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("hedge took %v", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow attempt was not canceled")
+	}
+}
+
+func TestDoBreaker(t *testing.T) {
+	ctx := context.Background()
+	// 熔断器状态变化时通过erlogs记录日志，需要先初始化元数据
+	metas.Initialize(&metas.Options{App: "test", Kind: metas.KindTool, Mode: metas.ModeLocal, Config: t.TempDir()})
+	b := breaker.New("retry-test", breaker.ConsecutiveFailures(2), breaker.OpenTimeout(time.Minute))
+
+	// 连续失败打开熔断器后不再执行也不再重试
+	calls := 0
+	res := Do(ctx, func(ctx context.Context) result.Interface[int] {
+		calls++
+		return result.Error[int](errors.New("failed"))
+	}, Num(5), Backoff(Constant(time.Millisecond)), Breaker(b))
+	if calls != 2 || !errors.Is(res.Err(), breaker.ErrCircuitOpen) {
+		t.Fatalf("calls = %d, err = %v", calls, res.Err())
+	}
+
+	res = Do(ctx, func(ctx context.Context) result.Interface[int] {
+		calls++
+		return result.Success(1)
+	}, Breaker(b))
+	if calls != 2 || !errors.Is(res.Err(), breaker.ErrCircuitOpen) {
+		t.Fatalf("calls = %d, err = %v", calls, res.Err())
+	}
+}
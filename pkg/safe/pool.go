@@ -0,0 +1,200 @@
+package safe
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Pool 限制并发数的任务池，同时执行的任务数达到上限时 Submit 阻塞等待，避免批量任务启动过多goroutine
+	Pool struct {
+		sem  chan struct{} // 执行槽位，容量为并发上限
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+
+		running   atomic.Int64  // 正在执行的任务数
+		waiting   atomic.Int64  // 等待槽位的提交数
+		submitted atomic.Uint64 // 已提交的任务数
+		completed atomic.Uint64 // 已完成的任务数（含失败）
+		failed    atomic.Uint64 // 返回错误或panic的任务数
+		panicked  atomic.Uint64 // panic的任务数
+	}
+
+	// PoolStats 任务池的运行指标
+	PoolStats struct {
+		Limit     int    // 并发上限
+		Running   int    // 正在执行的任务数
+		Waiting   int    // 等待槽位的提交数
+		Submitted uint64 // 已提交的任务数
+		Completed uint64 // 已完成的任务数（含失败）
+		Failed    uint64 // 返回错误或panic的任务数
+		Panicked  uint64 // panic的任务数
+	}
+)
+
+// NewPool 创建并发上限为limit的任务池，limit<=0时使用 runtime.GOMAXPROCS(0)
+func NewPool(limit int) *Pool {
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	return &Pool{sem: make(chan struct{}, limit)}
+}
+
+// Submit 提交任务，并发数达到上限时阻塞直到有空闲槽位或ctx取消。
+// 参数:
+//
+//	ctx: 控制等待槽位的时间，同时作为任务函数的上下文
+//	f: 任务函数，返回的错误和panic转换的 PanicError 由 Wait 汇总返回
+//
+// 返回值:
+//
+//	error: 等待槽位期间ctx取消时返回ctx的错误，任务未提交
+func (p *Pool) Submit(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.waiting.Add(1)
+	select {
+	case p.sem <- struct{}{}:
+		p.waiting.Add(-1)
+	case <-ctx.Done():
+		p.waiting.Add(-1)
+		return ctx.Err()
+	}
+
+	p.run(ctx, f)
+	return nil
+}
+
+// TrySubmit 在有空闲槽位时提交任务并返回true，否则不提交并返回false
+func (p *Pool) TrySubmit(ctx context.Context, f func(ctx context.Context) error) bool {
+	select {
+	case p.sem <- struct{}{}:
+		p.run(ctx, f)
+		return true
+	default:
+		return false
+	}
+}
+
+// run 在独立goroutine中执行已获取槽位的任务，结束后释放槽位
+func (p *Pool) run(ctx context.Context, f func(ctx context.Context) error) {
+	p.wg.Add(1)
+	p.submitted.Add(1)
+	p.running.Add(1)
+
+	go func() {
+		var err error
+		defer p.wg.Done()
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Stack: string(debug.Stack()), Value: r}
+				p.panicked.Add(1)
+			}
+
+			if err != nil {
+				p.failed.Add(1)
+				p.mu.Lock()
+				p.errs = append(p.errs, err)
+				p.mu.Unlock()
+			}
+
+			p.completed.Add(1)
+			p.running.Add(-1)
+			<-p.sem
+		}()
+
+		err = f(ctx)
+	}()
+}
+
+// Wait 阻塞直到已提交的任务全部完成，返回使用errors.Join组合的任务错误。
+// 返回后清空已收集的错误，任务池可继续使用
+func (p *Pool) Wait() error {
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := errors.Join(p.errs...)
+	p.errs = nil
+	return err
+}
+
+// Stats 返回任务池当前的运行指标
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Limit:     cap(p.sem),
+		Running:   int(p.running.Load()),
+		Waiting:   int(p.waiting.Load()),
+		Submitted: p.submitted.Load(),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+		Panicked:  p.panicked.Load(),
+	}
+}
+
+// Utilization 返回正在执行的任务数占并发上限的比例，取值[0, 1]
+func (s PoolStats) Utilization() float64 {
+	if s.Limit <= 0 {
+		return 0
+	}
+	return float64(s.Running) / float64(s.Limit)
+}
+
+// Map 以最多limit个并发对items逐个执行fn，按items的下标返回结果和错误。
+// 参数:
+//
+//	ctx: 上下文，取消后不再提交剩余的item，未执行的item对应的错误为ctx的错误
+//	items: 输入数据
+//	limit: 并发上限，<=0时使用 runtime.GOMAXPROCS(0)
+//	fn: 处理单个item的函数，panic转换为 PanicError
+//
+// 返回值:
+//
+//	outs: 与items下标一一对应的结果，失败的item为零值
+//	errs: 与items下标一一对应的错误，全部成功时为nil
+func Map[In, Out any](ctx context.Context, items []In, limit int, fn func(ctx context.Context, item In) (Out, error)) (outs []Out, errs []error) {
+	outs = make([]Out, len(items))
+	errs = make([]error, len(items))
+	pool := NewPool(limit)
+
+	for i, item := range items {
+		err := pool.Submit(ctx, func(ctx context.Context) error {
+			out, err := F(ctx, func(ctx context.Context) (Out, error) {
+				return fn(ctx, item)
+			})
+			if err != nil {
+				errs[i] = err
+				return err
+			}
+
+			outs[i] = out
+			return nil
+		})
+
+		if err != nil {
+			for j := i; j < len(items); j++ {
+				errs[j] = err
+			}
+			break
+		}
+	}
+
+	_ = pool.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return
+		}
+	}
+
+	return outs, nil
+}
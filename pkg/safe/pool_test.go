@@ -0,0 +1,98 @@
+package safe
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	pool := NewPool(2)
+
+	var running, peak atomic.Int64
+	for i := 0; i < 10; i++ {
+		err := pool.Submit(ctx, func(ctx context.Context) error {
+			defer running.Add(-1)
+
+			// 记录同时执行的最大任务数
+			n := running.Add(1)
+			for p := peak.Load(); n > p; p = peak.Load() {
+				if peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	if err := pool.Wait(); err != nil || peak.Load() > 2 {
+		t.Fatalf("Wait: %v, peak = %d, want <= 2", err, peak.Load())
+	}
+
+	// 槽位占满时Submit阻塞到ctx取消，TrySubmit立即返回
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		_ = pool.Submit(ctx, func(ctx context.Context) error {
+			<-release
+			panic("boom")
+		})
+	}
+
+	if pool.TrySubmit(ctx, func(ctx context.Context) error { return nil }) {
+		t.Fatal("TrySubmit succeeded on a full pool")
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(timeout, func(ctx context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Submit: %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if stats := pool.Stats(); stats.Running != 2 || stats.Utilization() != 1 {
+		t.Fatalf("Stats: %+v", stats)
+	}
+
+	close(release)
+	var pe *PanicError
+	if err := pool.Wait(); !errors.As(err, &pe) {
+		t.Fatalf("Wait: %v, want PanicError", err)
+	}
+
+	if stats := pool.Stats(); stats.Submitted != 12 || stats.Completed != 12 || stats.Panicked != 2 || stats.Running != 0 {
+		t.Fatalf("Stats: %+v", stats)
+	}
+}
+
+func TestMap(t *testing.T) {
+	failed := errors.New("failed")
+
+	outs, errs := Map(context.Background(), []int{1, 2, 3, 4}, 2, func(ctx context.Context, item int) (int, error) {
+		switch item {
+		case 2:
+			return 20, failed
+		case 3:
+			panic("boom")
+		}
+		return item * 10, nil
+	})
+
+	var pe *PanicError
+	// 失败的item结果为零值
+	if outs[0] != 10 || outs[1] != 0 || outs[3] != 40 || errs[0] != nil || !errors.Is(errs[1], failed) || !errors.As(errs[2], &pe) {
+		t.Fatalf("Map = %v, %v", outs, errs)
+	}
+
+	if _, errs = Map(context.Background(), []int{1, 2}, 0, func(ctx context.Context, item int) (int, error) {
+		return item, nil
+	}); errs != nil {
+		t.Fatalf("Map: %v, want nil", errs)
+	}
+}